@@ -1,43 +1,335 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
+	"image"
+	"image/gif"
 	"image/jpeg"
-	"log"
+	"image/png"
+	"io"
 	"os"
-	"wfc/pkg/utils"
+	"path/filepath"
+	"strings"
+	"time"
 	"wfc/pkg/wfc"
 )
 
+// Exit codes returned by the cli
+const (
+	exitSuccess       = 0 // Generation finished without a contradiction
+	exitError         = 1 // Input or output could not be read or written
+	exitUsage         = 2 // Invalid command line
+	exitContradiction = 3 // Generation ran into a contradiction
+	exitTimeout       = 4 // Generation did not finish within the timeout
+)
+
+const usage = `usage: wfc <command> [flags]
+
+commands:
+  tiled     generate an image from a tiled data file
+  overlap   generate an image from a sample image
+  samples   generate every sample of an original WaveFunctionCollapse samples.xml
+
+Run 'wfc <command> -h' for the flags of a command.
+`
+
 func main() {
-	data := wfc.MakeTiledData("internal/input/", "pipe_data.json")
-	t := wfc.NewTiledModel(data, 20, 20, false)
+	os.Exit(run(os.Args[1:]))
+}
 
-	i, _ := t.Generate()
-	// with Makefile remove ../../
-	f, err := os.Create("internal/output/img.jpg")
-	if err != nil {
-		log.Fatalln(err)
+func run(args []string) int {
+	if len(args) < 1 {
+		fmt.Fprint(os.Stderr, usage)
+		return exitUsage
 	}
 
-	err = jpeg.Encode(f, i, nil)
-	if err != nil {
-		log.Fatalln(err)
+	switch args[0] {
+	case "tiled":
+		return runTiled(args[1:])
+	case "overlap":
+		return runOverlap(args[1:])
+	case "samples":
+		return runSamples(args[1:])
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(os.Stdout, usage)
+		return exitSuccess
+	default:
+		fmt.Fprintf(os.Stderr, "wfc: unknown command %q\n\n", args[0])
+		fmt.Fprint(os.Stderr, usage)
+		return exitUsage
 	}
+}
+
+// Default -budget, large enough to get past the usual contradictions but
+// bounding the time spent on outputs that cannot be completed
+const defaultBudget = 10000
 
-	img, err := utils.LoadImage("internal/input/maze.png")
+// Frames aimed for when -every is 0, one observation per cell at most
+// so larger outputs get fewer frames per observation
+const animationFrames = 200
+
+// Flags shared by every command
+type commonFlags struct {
+	seed      int64
+	out       string
+	format    string
+	backtrack bool
+	budget    int
+	timeout   time.Duration
+	selector  string
+	pattern   string
+	animate   string
+	every     int
+	result    string
+	recorder  *wfc.Recorder // Set by record when -animate is given
+}
+
+// Cell selection heuristics by -select name
+var selectors = map[string]wfc.CellSelector{
+	"entropy":  wfc.MinEntropy{},
+	"mrv":      wfc.MinRemainingValues{},
+	"scanline": wfc.Scanline{},
+	"random":   wfc.RandomCell{},
+	"spiral":   wfc.Spiral{},
+}
+
+func (c *commonFlags) register(fs *flag.FlagSet, out string) {
+	c.registerGeneration(fs)
+	fs.StringVar(&c.out, "out", out, "output image path")
+	fs.StringVar(&c.format, "format", "", "output format: png, jpeg or gif (default from -out extension)")
+	fs.StringVar(&c.animate, "animate", "", "record the generation as a .gif, or as pngs named by a pattern like frames/%04d.png")
+	fs.IntVar(&c.every, "every", 0, "observations between -animate frames, 0 for about 200 frames whatever the output size")
+	fs.StringVar(&c.result, "result", "", "also write the chosen tiles or patterns per cell to a .json or .csv file")
+}
+
+// Registers the flags driving generation alone, for commands writing
+// their outputs themselves
+func (c *commonFlags) registerGeneration(fs *flag.FlagSet) {
+	fs.Int64Var(&c.seed, "seed", 0, "random seed, a time based seed is used when not set")
+	fs.BoolVar(&c.backtrack, "backtrack", false, "undo observations on contradiction instead of failing")
+	fs.IntVar(&c.budget, "budget", defaultBudget, "maximum number of backtracks, 0 for no limit")
+	fs.DurationVar(&c.timeout, "timeout", 0, "stop generation after this long, 0 for no limit")
+	fs.StringVar(&c.selector, "select", "entropy", "cell selection: entropy, mrv, scanline, random or spiral")
+	fs.StringVar(&c.pattern, "pattern", "weighted", "pattern selection: weighted, usage, least or lowest")
+}
+
+// Pattern selection strategies by -pattern name, group shares usage
+// counts between patterns
+func patternSelector(name string, group []int) (wfc.PatternSelector, bool) {
+	switch name {
+	case "weighted":
+		return wfc.WeightedRandom{}, true
+	case "usage":
+		return wfc.UsageWeighted{Group: group}, true
+	case "least":
+		return wfc.LeastUsed{}, true
+	case "lowest":
+		return wfc.LowestIndex{}, true
+	}
+	return nil, false
+}
+
+// Applies the generation settings to a model, group shares pattern
+// usage counts between patterns and may be nil
+func (c *commonFlags) configure(b *wfc.BaseModel, group []int) {
+	b.SetSeed(c.seed)
+	if c.backtrack {
+		b.SetBacktracking(c.budget)
+	}
+	b.SetCellSelector(selectors[c.selector])
+	selector, _ := patternSelector(c.pattern, group)
+	b.SetPatternSelector(selector)
+}
+
+// Records the generation when -animate is given, render draws a frame.
+// Png frames are written as they are captured
+func (c *commonFlags) record(b *wfc.BaseModel, render func() image.Image) {
+	if c.animate == "" {
+		return
+	}
+	every := c.every
+	if every == 0 {
+		every = b.Fmx * b.Fmy / animationFrames
+	}
+	c.recorder = wfc.NewRecorder(render, every)
+	if !strings.EqualFold(filepath.Ext(c.animate), ".gif") {
+		c.recorder.Pattern = c.animate
+	}
+	b.SetObserver(c.recorder)
+}
+
+// Writes the recorded gif to -animate, or reports the first error writing
+// the png frames
+func (c *commonFlags) saveAnimation() error {
+	if c.recorder.Pattern != "" {
+		return c.recorder.Err()
+	}
+
+	f, err := os.Create(c.animate)
 	if err != nil {
-		log.Fatalln(err)
+		return err
+	}
+	err = c.recorder.WriteGIF(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Context bounding generation by the timeout
+func (c *commonFlags) context() (context.Context, context.CancelFunc) {
+	if c.timeout > 0 {
+		return context.WithTimeout(context.Background(), c.timeout)
+	}
+	return context.WithCancel(context.Background())
+}
+
+// Fills in defaults that depend on which flags were actually given
+func (c *commonFlags) resolve(fs *flag.FlagSet) error {
+	seedSet := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "seed" {
+			seedSet = true
+		}
+	})
+	if !seedSet {
+		c.seed = time.Now().UnixNano()
 	}
-	o := wfc.NewOverlappingModel(img, 3, 16, 16, true, true, 2, false)
 
-	i, _ = o.Generate()
-	f, err = os.Create("internal/output/img2.jpg")
+	if _, ok := selectors[c.selector]; !ok {
+		return fmt.Errorf("unknown cell selection %q", c.selector)
+	}
+	if _, ok := patternSelector(c.pattern, nil); !ok {
+		return fmt.Errorf("unknown pattern selection %q", c.pattern)
+	}
+
+	if c.every < 0 {
+		return fmt.Errorf("-every must not be negative, got %d", c.every)
+	}
+	if c.animate != "" && !strings.EqualFold(filepath.Ext(c.animate), ".gif") && !strings.Contains(c.animate, "%") {
+		return fmt.Errorf("-animate wants a .gif or a png pattern like frames/%%04d.png, got %q", c.animate)
+	}
+
+	switch strings.ToLower(filepath.Ext(c.result)) {
+	case ".json", ".csv":
+	default:
+		if c.result != "" {
+			return fmt.Errorf("-result wants a .json or .csv file, got %q", c.result)
+		}
+	}
+
+	if c.format == "" {
+		c.format = strings.TrimPrefix(strings.ToLower(filepath.Ext(c.out)), ".")
+	}
+	switch c.format {
+	case "png", "gif":
+	case "jpg", "jpeg":
+		c.format = "jpeg"
+	default:
+		return fmt.Errorf("unknown output format %q", c.format)
+	}
+
+	return nil
+}
+
+// Parses args into fs, returning an exit code if the command should stop
+func parse(fs *flag.FlagSet, c *commonFlags, args []string) (int, bool) {
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitSuccess, false
+		}
+		return exitUsage, false
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "wfc %s: unexpected argument %q\n", fs.Name(), fs.Arg(0))
+		fs.Usage()
+		return exitUsage, false
+	}
+	if err := c.resolve(fs); err != nil {
+		fmt.Fprintf(os.Stderr, "wfc %s: %v\n", fs.Name(), err)
+		return exitUsage, false
+	}
+	return exitSuccess, true
+}
+
+// Result of a model, see wfc.TiledResult
+type result interface {
+	WriteJSON(w io.Writer) error
+	WriteCSV(w io.Writer) error
+}
+
+// Writes the result to -result in the format of its extension
+func (c *commonFlags) saveResult(r result) error {
+	f, err := os.Create(c.result)
 	if err != nil {
-		log.Fatalln(err)
+		return err
 	}
 
-	err = jpeg.Encode(f, i, nil)
+	if strings.EqualFold(filepath.Ext(c.result), ".csv") {
+		err = r.WriteCSV(f)
+	} else {
+		err = r.WriteJSON(f)
+	}
+
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Writes the generated image and result, and maps the generation result
+// to an exit code
+func finish(name string, c *commonFlags, img image.Image, r result, success bool, genErr error) int {
+	if err := saveImage(c.out, c.format, img); err != nil {
+		fmt.Fprintf(os.Stderr, "wfc %s: %v\n", name, err)
+		return exitError
+	}
+	if c.result != "" {
+		if err := c.saveResult(r); err != nil {
+			fmt.Fprintf(os.Stderr, "wfc %s: -result: %v\n", name, err)
+			return exitError
+		}
+	}
+	if c.recorder != nil {
+		if err := c.saveAnimation(); err != nil {
+			fmt.Fprintf(os.Stderr, "wfc %s: -animate: %v\n", name, err)
+			return exitError
+		}
+	}
+
+	if genErr != nil {
+		fmt.Fprintf(os.Stderr, "wfc %s: %v (seed %d), partial output written to %s\n", name, genErr, c.seed, c.out)
+		return exitTimeout
+	}
+
+	if !success {
+		fmt.Fprintf(os.Stderr, "wfc %s: contradiction (seed %d), partial output written to %s\n", name, c.seed, c.out)
+		return exitContradiction
+	}
+
+	fmt.Fprintf(os.Stdout, "seed %d, output written to %s\n", c.seed, c.out)
+	return exitSuccess
+}
+
+func saveImage(file, format string, img image.Image) error {
+	f, err := os.Create(file)
 	if err != nil {
-		log.Fatalln(err)
+		return err
+	}
+
+	switch format {
+	case "png":
+		err = png.Encode(f, img)
+	case "jpeg":
+		err = jpeg.Encode(f, img, nil)
+	case "gif":
+		err = gif.Encode(f, img, nil)
+	}
+
+	if cerr := f.Close(); err == nil {
+		err = cerr
 	}
+	return err
 }
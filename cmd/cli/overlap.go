@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"wfc/pkg/utils"
+	"wfc/pkg/wfc"
+)
+
+func runOverlap(args []string) int {
+	var (
+		common        commonFlags
+		input         string
+		n             int
+		width         int
+		height        int
+		periodicInput bool
+		periodic      bool
+		symmetry      int
+		ground        bool
+		edges         []wfc.Edge
+	)
+
+	fs := flag.NewFlagSet("overlap", flag.ContinueOnError)
+	fs.StringVar(&input, "input", "", "sample image (required)")
+	fs.IntVar(&n, "n", 3, "pattern size")
+	fs.IntVar(&width, "width", 48, "output width in pixels")
+	fs.IntVar(&height, "height", 48, "output height in pixels")
+	fs.BoolVar(&periodicInput, "periodic-input", true, "sample tessellates")
+	fs.BoolVar(&periodic, "periodic", false, "output tessellates")
+	fs.IntVar(&symmetry, "symmetry", 8, "number of sample symmetries to use (1-8)")
+	fs.BoolVar(&ground, "ground", false, "pin the bottom left sample pattern to the bottom row")
+	fs.Func("edges", "sample edges pinned to the same output edge, comma separated: bottom, top, left, right", func(value string) error {
+		for _, name := range strings.Split(value, ",") {
+			e, ok := edgeNames[strings.TrimSpace(name)]
+			if !ok {
+				return fmt.Errorf("unknown edge %q", name)
+			}
+			edges = append(edges, e)
+		}
+		return nil
+	})
+	common.register(fs, "overlap.png")
+
+	if code, ok := parse(fs, &common, args); !ok {
+		return code
+	}
+	if input == "" {
+		fmt.Fprintln(os.Stderr, "wfc overlap: -input is required")
+		fs.Usage()
+		return exitUsage
+	}
+	if n < 1 || width < n || height < n {
+		fmt.Fprintln(os.Stderr, "wfc overlap: -n must be positive and no larger than -width and -height")
+		return exitUsage
+	}
+	if ground && len(edges) > 0 {
+		fmt.Fprintln(os.Stderr, "wfc overlap: -ground and -edges cannot be used together")
+		return exitUsage
+	}
+	if symmetry < 1 || symmetry > 8 {
+		fmt.Fprintln(os.Stderr, "wfc overlap: -symmetry must be between 1 and 8")
+		return exitUsage
+	}
+
+	img, err := utils.LoadImage(input)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "wfc overlap: %v\n", err)
+		return exitError
+	}
+
+	var model *wfc.OverlappingModel
+	if len(edges) > 0 {
+		model = wfc.NewOverlappingModelEdges(img, n, width, height, periodicInput, periodic, symmetry, edges...)
+	} else {
+		model = wfc.NewOverlappingModel(img, n, width, height, periodicInput, periodic, symmetry, ground)
+	}
+	common.configure(model.BaseModel, nil)
+	common.record(model.BaseModel, model.RenderIncompleteImage)
+
+	ctx, cancel := common.context()
+	defer cancel()
+
+	out, success, err := model.GenerateContext(ctx)
+	return finish("overlap", &common, out, model.Result(), success, err)
+}
+
+// Sample edges by -edges name
+var edgeNames = map[string]wfc.Edge{
+	"bottom": wfc.EdgeBottom,
+	"top":    wfc.EdgeTop,
+	"left":   wfc.EdgeLeft,
+	"right":  wfc.EdgeRight,
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"wfc/pkg/wfc"
+)
+
+// Model generated by the samples command
+type sampleModel interface {
+	IterateContext(ctx context.Context, iterations int) (image.Image, bool, bool, error)
+	GenerateContext(ctx context.Context) (image.Image, bool, error)
+}
+
+func runSamples(args []string) int {
+	var (
+		file  string
+		dir   string
+		tries int
+		c     commonFlags
+	)
+
+	// Outputs are numbered pngs in the -out directory, the seed counts up
+	// from -seed
+	fs := flag.NewFlagSet("samples", flag.ContinueOnError)
+	c.registerGeneration(fs)
+	fs.StringVar(&file, "file", "", "samples.xml of the original WaveFunctionCollapse repository (required)")
+	fs.StringVar(&dir, "dir", "", "directory holding samples/ and tilesets/ (default the directory of -file)")
+	fs.StringVar(&c.out, "out", "output", "directory to write the outputs to")
+	fs.IntVar(&tries, "tries", 10, "attempts per output before giving up on a contradiction")
+	c.format = "png"
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitSuccess
+		}
+		return exitUsage
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "wfc samples: unexpected argument %q\n", fs.Arg(0))
+		fs.Usage()
+		return exitUsage
+	}
+	if file == "" {
+		fmt.Fprintln(os.Stderr, "wfc samples: -file is required")
+		fs.Usage()
+		return exitUsage
+	}
+	if err := c.resolve(fs); err != nil {
+		fmt.Fprintf(os.Stderr, "wfc samples: %v\n", err)
+		return exitUsage
+	}
+	if tries < 1 {
+		fmt.Fprintf(os.Stderr, "wfc samples: -tries must be positive, got %d\n", tries)
+		return exitUsage
+	}
+	if dir == "" {
+		dir = filepath.Dir(file)
+	}
+
+	samples, err := wfc.LoadSamples(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "wfc samples: %v\n", err)
+		return exitError
+	}
+	if err := os.MkdirAll(c.out, 0o755); err != nil {
+		fmt.Fprintf(os.Stderr, "wfc samples: %v\n", err)
+		return exitError
+	}
+
+	code := exitSuccess
+	fail := func(exit int) {
+		// Keep the most serious failure, errors before timeouts before
+		// contradictions
+		if code == exitSuccess || exit == exitError || (exit == exitTimeout && code == exitContradiction) {
+			code = exit
+		}
+	}
+
+	for i, s := range samples {
+		newModel, err := sampleModelFunc(s, dir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "wfc samples: %s: %v\n", s.Name, err)
+			fail(exitError)
+			continue
+		}
+
+		for k := 0; k < s.Screenshots; k++ {
+			path := filepath.Join(c.out, fmt.Sprintf("%s %d %d.png", s.Name, i, k))
+			var img image.Image
+			success := false
+			for try := 0; try < tries && !success && err == nil; try++ {
+				model, base := newModel()
+				c.configure(base, nil)
+				img, success, err = generateSample(model, s.Limit, &c)
+				if !success && err == nil {
+					fmt.Fprintf(os.Stderr, "wfc samples: %s: contradiction (seed %d)\n", s.Name, c.seed)
+				}
+				c.seed++
+			}
+
+			if serr := saveImage(path, c.format, img); serr != nil {
+				fmt.Fprintf(os.Stderr, "wfc samples: %v\n", serr)
+				fail(exitError)
+				continue
+			}
+			switch {
+			case err != nil:
+				fmt.Fprintf(os.Stderr, "wfc samples: %s: %v, partial output written to %s\n", s.Name, err, path)
+				fail(exitTimeout)
+				err = nil
+			case !success:
+				fmt.Fprintf(os.Stderr, "wfc samples: %s: gave up after %d tries, partial output written to %s\n", s.Name, tries, path)
+				fail(exitContradiction)
+			default:
+				fmt.Fprintf(os.Stdout, "seed %d, output written to %s\n", c.seed-1, path)
+			}
+		}
+	}
+	return code
+}
+
+// Loads the input of a sample once, returning a function making a fresh
+// model of it
+func sampleModelFunc(s wfc.Sample, dir string) (func() (sampleModel, *wfc.BaseModel), error) {
+	if s.Kind == wfc.SampleOverlapping {
+		img, err := s.Image(dir)
+		if err != nil {
+			return nil, err
+		}
+		if s.Symmetry < 1 || s.Symmetry > 8 || s.N < 1 || s.Width < s.N || s.Height < s.N {
+			return nil, fmt.Errorf("invalid N %d, size %dx%d or symmetry %d", s.N, s.Width, s.Height, s.Symmetry)
+		}
+		return func() (sampleModel, *wfc.BaseModel) {
+			m := wfc.NewOverlappingModel(img, s.N, s.Width, s.Height, s.PeriodicInput, s.Periodic, s.Symmetry, s.Ground)
+			return m, m.BaseModel
+		}, nil
+	}
+
+	data, err := s.Data(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := data.Validate(); err != nil {
+		return nil, err
+	}
+	if s.Width < 1 || s.Height < 1 {
+		return nil, fmt.Errorf("invalid size %dx%d", s.Width, s.Height)
+	}
+	return func() (sampleModel, *wfc.BaseModel) {
+		m := wfc.NewTiledModel(data, s.Width, s.Height, s.Periodic)
+		return m, m.BaseModel
+	}, nil
+}
+
+// Runs a model to completion, or for limit observations if positive as
+// the original samples do, an output stopped by the limit counts as a
+// success
+func generateSample(model sampleModel, limit int, c *commonFlags) (image.Image, bool, error) {
+	ctx, cancel := c.context()
+	defer cancel()
+
+	if limit > 0 {
+		img, finished, success, err := model.IterateContext(ctx, limit)
+		return img, success || (!finished && err == nil), err
+	}
+	return model.GenerateContext(ctx)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"wfc/pkg/wfc"
+)
+
+func runTiled(args []string) int {
+	var (
+		common   commonFlags
+		data     string
+		width    int
+		height   int
+		periodic bool
+		pins     []pin
+		tmx      string
+		subset   string
+	)
+
+	fs := flag.NewFlagSet("tiled", flag.ContinueOnError)
+	fs.StringVar(&data, "data", "", "tiled data json file, Tiled .tsx tileset or original WaveFunctionCollapse .xml tileset (required)")
+	fs.IntVar(&width, "width", 20, "output width in tiles")
+	fs.IntVar(&height, "height", 20, "output height in tiles")
+	fs.BoolVar(&periodic, "periodic", false, "output tessellates")
+	fs.Func("pin", "restrict a cell to tiles, as x,y=tile|tile 1 (repeatable)", func(value string) error {
+		p, err := parsePin(value)
+		if err != nil {
+			return err
+		}
+		pins = append(pins, p)
+		return nil
+	})
+	fs.StringVar(&tmx, "tmx", "", "also write a Tiled .tmx map, needs a .tsx -data")
+	fs.StringVar(&subset, "subset", "", "only use the tiles of this subset, needs a .xml -data")
+	common.register(fs, "tiled.png")
+
+	if code, ok := parse(fs, &common, args); !ok {
+		return code
+	}
+	if data == "" {
+		fmt.Fprintln(os.Stderr, "wfc tiled: -data is required")
+		fs.Usage()
+		return exitUsage
+	}
+	if width < 1 || height < 1 {
+		fmt.Fprintln(os.Stderr, "wfc tiled: -width and -height must be positive")
+		return exitUsage
+	}
+
+	isTSX := strings.EqualFold(filepath.Ext(data), ".tsx")
+	isXML := strings.EqualFold(filepath.Ext(data), ".xml")
+	if tmx != "" && !isTSX {
+		fmt.Fprintln(os.Stderr, "wfc tiled: -tmx needs a .tsx -data")
+		return exitUsage
+	}
+	if subset != "" && !isXML {
+		fmt.Fprintln(os.Stderr, "wfc tiled: -subset needs a .xml -data")
+		return exitUsage
+	}
+
+	var td wfc.TiledData
+	var tsx wfc.TSXData
+	var err error
+	if isTSX {
+		tsx, err = wfc.LoadTSXData(data)
+		td = tsx.TiledData
+	} else if isXML {
+		td, err = wfc.LoadXMLData(data, subset)
+	} else {
+		td, err = wfc.LoadTiledData(filepath.Dir(data)+"/", filepath.Base(data))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "wfc tiled: %v\n", err)
+		return exitError
+	}
+	if err := td.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "wfc tiled: %v\n", err)
+		return exitError
+	}
+
+	model := wfc.NewTiledModel(td, width, height, periodic)
+	common.configure(model.BaseModel, model.TileOf)
+	common.record(model.BaseModel, model.RenderIncompleteImage)
+	for _, p := range pins {
+		if err := model.Constrain(p.x, p.y, p.tiles...); err != nil {
+			fmt.Fprintf(os.Stderr, "wfc tiled: -pin: %v\n", err)
+			return exitUsage
+		}
+	}
+
+	ctx, cancel := common.context()
+	defer cancel()
+
+	img, success, err := model.GenerateContext(ctx)
+	if tmx != "" {
+		if err := saveTMX(model, tsx, data, tmx); err != nil {
+			fmt.Fprintf(os.Stderr, "wfc tiled: -tmx: %v\n", err)
+			return exitError
+		}
+	}
+	return finish("tiled", &common, img, model.Result(), success, err)
+}
+
+// Writes the map to file, referencing the tileset relative to it
+func saveTMX(model *wfc.TiledModel, tsx wfc.TSXData, tileset, file string) error {
+	dir, err := filepath.Abs(filepath.Dir(file))
+	if err != nil {
+		return err
+	}
+	source, err := filepath.Abs(tileset)
+	if err != nil {
+		return err
+	}
+	if rel, err := filepath.Rel(dir, source); err == nil {
+		source = rel
+	}
+
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	err = model.WriteTMX(f, tsx, filepath.ToSlash(source))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// A cell restricted to some tiles
+type pin struct {
+	x     int
+	y     int
+	tiles []string
+}
+
+// Parses x,y=tile|tile
+func parsePin(value string) (pin, error) {
+	cell, tiles, ok := strings.Cut(value, "=")
+	if !ok || tiles == "" {
+		return pin{}, fmt.Errorf("want x,y=tile, got %q", value)
+	}
+
+	var p pin
+	if _, err := fmt.Sscanf(cell, "%d,%d", &p.x, &p.y); err != nil {
+		return pin{}, fmt.Errorf("want x,y=tile, got %q", value)
+	}
+	p.tiles = strings.Split(tiles, "|")
+	return p, nil
+}
@@ -0,0 +1,101 @@
+<?xml version="1.0" encoding="UTF-8"?>
+<tileset version="1.10" tiledversion="1.10.2" name="castle" tilewidth="7" tileheight="7" tilecount="11" columns="0">
+ <grid orientation="orthogonal" width="1" height="1"/>
+ <tile id="0">
+  <properties>
+   <property name="wfc.name" value="bridge"/>
+   <property name="wfc.symmetry" value="I"/>
+   <property name="wfc.right.1" value="river 1, riverturn 1"/>
+   <property name="wfc.right" value="road 1, roadturn 1, t, t 3, wallroad"/>
+  </properties>
+  <image source="castle/bridge.png" width="7" height="7"/>
+ </tile>
+ <tile id="1">
+  <properties>
+   <property name="wfc.name" value="ground"/>
+   <property name="wfc.symmetry" value="X"/>
+   <property name="wfc.right" value="ground, river, riverturn, road, roadturn, t 1, tower, wall"/>
+  </properties>
+  <image source="castle/ground.png" width="7" height="7"/>
+ </tile>
+ <tile id="2">
+  <properties>
+   <property name="wfc.name" value="river"/>
+   <property name="wfc.symmetry" value="I"/>
+   <property name="wfc.right.1" value="river 1, riverturn 1, wallriver"/>
+   <property name="wfc.right" value="road, roadturn, t 1, tower, wall"/>
+  </properties>
+  <image source="castle/river.png" width="7" height="7"/>
+ </tile>
+ <tile id="3">
+  <properties>
+   <property name="wfc.name" value="riverturn"/>
+   <property name="wfc.symmetry" value="L"/>
+   <property name="wfc.right" value="riverturn 2, wallriver"/>
+  </properties>
+  <image source="castle/riverturn.png" width="7" height="7"/>
+ </tile>
+ <tile id="4">
+  <properties>
+   <property name="wfc.name" value="road"/>
+   <property name="wfc.symmetry" value="I"/>
+   <property name="wfc.right" value="riverturn, tower, wall"/>
+   <property name="wfc.right.1" value="road 1, t, t 3, wallroad"/>
+  </properties>
+  <image source="castle/road.png" width="7" height="7"/>
+ </tile>
+ <tile id="5">
+  <properties>
+   <property name="wfc.name" value="roadturn"/>
+   <property name="wfc.symmetry" value="L"/>
+   <property name="wfc.right.1" value="riverturn, tower, wall"/>
+   <property name="wfc.right.2" value="riverturn, tower"/>
+   <property name="wfc.right" value="road 1, roadturn 2, t, wallroad"/>
+  </properties>
+  <image source="castle/roadturn.png" width="7" height="7"/>
+ </tile>
+ <tile id="6">
+  <properties>
+   <property name="wfc.name" value="t"/>
+   <property name="wfc.symmetry" value="T"/>
+   <property name="wfc.right.3" value="riverturn, tower, wall"/>
+   <property name="wfc.right" value="t 2, wallroad"/>
+   <property name="wfc.right.1" value="wallroad"/>
+  </properties>
+  <image source="castle/t.png" width="7" height="7"/>
+ </tile>
+ <tile id="7">
+  <properties>
+   <property name="wfc.name" value="tower"/>
+   <property name="wfc.symmetry" value="L"/>
+   <property name="wfc.right.1" value="riverturn"/>
+   <property name="wfc.right.2" value="riverturn"/>
+   <property name="wfc.right" value="wall 1, wallriver 1, wallroad 1"/>
+  </properties>
+  <image source="castle/tower.png" width="7" height="7"/>
+ </tile>
+ <tile id="8">
+  <properties>
+   <property name="wfc.name" value="wall"/>
+   <property name="wfc.symmetry" value="I"/>
+   <property name="wfc.right" value="riverturn"/>
+   <property name="wfc.right.1" value="wall 1, wallriver 1, wallroad 1"/>
+  </properties>
+  <image source="castle/wall.png" width="7" height="7"/>
+ </tile>
+ <tile id="9">
+  <properties>
+   <property name="wfc.name" value="wallriver"/>
+   <property name="wfc.symmetry" value="I"/>
+   <property name="wfc.right.1" value="wallroad 1"/>
+  </properties>
+  <image source="castle/wallriver.png" width="7" height="7"/>
+ </tile>
+ <tile id="10">
+  <properties>
+   <property name="wfc.name" value="wallroad"/>
+   <property name="wfc.symmetry" value="I"/>
+  </properties>
+  <image source="castle/wallroad.png" width="7" height="7"/>
+ </tile>
+</tileset>
@@ -1,7 +1,7 @@
 package wfc
 
 import (
-	"math"
+	"context"
 	"math/rand"
 	"time"
 )
@@ -10,149 +10,266 @@ type BaseModel struct {
 	InitField  bool           // Generation initiliazed?
 	RngSet     bool           // Random number generator set by user?
 	GenSuccess bool           // Generation has run into a contradiction?
-	Wave       [][][]bool     // All possible patterns (t) that could fit (x, y)
-	Changes    [][]bool       // Chabges made in interation of propagation
+	wave       Wave           // All possible patterns (t) that could fit (x, y)
 	Stationary []float64      // Array of weights for patterns
 	T          int            // Count of patterns
 	Periodic   bool           // Tessellates?
 	Fmx        int            // Width
 	Fmy        int            // Height
 	Rng        func() float64 // Random number generator supplied at gen time
+	rng        *countingRng   // Generator behind Rng when seeded by SetSeed or Clear, nil otherwise
+
+	CellSelector    CellSelector    // Chooses the next cell to observe, nil for MinEntropy
+	PatternSelector PatternSelector // Chooses the pattern of an observed cell, nil for WeightedRandom
+	Observer        Observer        // Receives generation events, nil for none
+
+	Backtracking    bool         // Undo observations on contradiction instead of failing?
+	BacktrackBudget int          // Maximum backtracks per generation, 0 for no limit
+	Backtracks      int          // Backtracks made in the current generation
+	history         []decision   // Observations that can be undone
+	constraints     []constraint // Patterns allowed at cells before generation
+	trail           []banned     // Every ban since clearing, in order
+
+	Topology       Topology  // How cells connect, the directions patterns are propagated in
+	Adjacency      [][][]int // Patterns allowed in direction d from a pattern [d][t1][]t2
+	initialSupport []int32   // Support of each pattern in an empty wave [t*d]
+	compatible     []int32   // Remaining support of each pattern [x][y][t][d]
+	stack          []banned  // Banned patterns waiting to be propagated
+
+	weightLogWeights       []float64 // Stationary[t] * log(Stationary[t])
+	sumOfWeights           float64   // Sum of Stationary
+	sumOfWeightLogWeights  float64   // Sum of weightLogWeights
+	sumsOfOnes             []int     // Patterns remaining in each cell
+	sumsOfWeights          []float64 // Weight of the patterns remaining in each cell
+	sumsOfWeightLogWeights []float64 // weightLogWeights of the patterns remaining in each cell
+	entropies              []float64 // Shannon entropy of each cell
+	noise                  []float64 // Tie breaker added to the entropy of each cell
+	frontier               cellHeap  // Undecided cells by priority
+	distribution           []float64 // Scratch space for choosing a pattern
+	contradiction          bool      // Has a cell run out of patterns?
+	used                   []int     // Cells decided as each pattern
 }
 
-func (b *BaseModel) Observe(sm Collapser) bool {
-	min := 1000.0
-	argminx := -1
-	argminy := -1
-	distribution := make([]float64, b.T)
-
-	for x := 0; x < b.Fmx; x++ {
-		for y := 0; y < b.Fmy; y++ {
-			if sm.OnBoundary(x, y) {
-				continue
-			}
-
-			sum := 0.0
-
-			for t := 0; t < b.T; t++ {
-				if b.Wave[x][y][t] {
-					distribution[t] = b.Stationary[t]
-				} else {
-					distribution[t] = 0.0
-				}
-				sum += distribution[t]
-			}
-
-			if sum == 0.0 {
-				b.GenSuccess = false
-				return true // finished, unsuccessful
-			}
-
-			for t := 0; t < b.T; t++ {
-				distribution[t] /= sum
-			}
-
-			entropy := 0.0
-
-			for i := 0; i < len(distribution); i++ {
-				if distribution[i] > 0.0 {
-					entropy += -distribution[i] * math.Log(distribution[i])
-				}
-			}
-
-			noise := 0.000001 * b.Rng()
-
-			if entropy > 0 && entropy+noise < min {
-				min = entropy + noise
-				argminx = x
-				argminy = y
-			}
+// An observation along with the length of the trail before it was made
+type decision struct {
+	x     int
+	y     int
+	t     int
+	trail int
+}
+
+func (b *BaseModel) Observe(sm Solver) bool {
+	if b.contradiction {
+		if b.backtrack(sm) {
+			return false // not finished, retry from an earlier state
+		}
+		b.GenSuccess = false
+		if b.Observer != nil {
+			b.Observer.Finished(false)
 		}
+		return true // finished, unsuccessful
 	}
 
-	if argminx == -1 && argminy == -1 {
+	argminx, argminy, ok := b.nextCell()
+	if !ok {
 		b.GenSuccess = true
+		if b.Observer != nil {
+			b.Observer.Finished(true)
+		}
 		return true
 	}
 
+	r := b.selectPattern(argminx, argminy)
+	if b.Observer != nil {
+		b.Observer.CellObserved(argminx, argminy, r)
+	}
+
+	if b.Backtracking {
+		b.history = append(b.history, decision{argminx, argminy, r, len(b.trail)})
+	}
+
 	for t := 0; t < b.T; t++ {
-		if b.Wave[argminx][argminy][t] {
-			distribution[t] = b.Stationary[t]
-		} else {
-			distribution[t] = 0.0
+		if t != r {
+			b.ban(argminx, argminy, t)
 		}
 	}
 
-	r := randomIndice(distribution, b.Rng())
+	return false
+}
+
+// Undoes the last observation and bans the pattern it chose, returns
+// false if there is nothing to undo or the budget is spent
+func (b *BaseModel) backtrack(sm Solver) bool {
+	if !b.Backtracking || len(b.history) == 0 {
+		return false
+	}
+	if b.BacktrackBudget > 0 && b.Backtracks >= b.BacktrackBudget {
+		return false
+	}
+
+	d := b.history[len(b.history)-1]
+	b.history = b.history[:len(b.history)-1]
+	b.Backtracks++
 
-	for t := 0; t < b.T; t++ {
-		b.Wave[argminx][argminy][t] = (t == r)
+	for len(b.trail) > d.trail {
+		e := b.trail[len(b.trail)-1]
+		b.trail = b.trail[:len(b.trail)-1]
+		b.unban(sm, e)
 	}
+	b.stack = b.stack[:0]
+	b.contradiction = false
 
-	b.Changes[argminx][argminy] = true
+	b.ban(d.x, d.y, d.t)
 
-	return false
+	for sm.Propagate() {
+		// Empty loop
+	}
+
+	return true
 }
 
-func (b *BaseModel) IterateOnce(sm Collapser) bool {
+func (b *BaseModel) IterateOnce(sm Solver) bool {
+	finished, _ := b.iterateOnce(context.Background(), sm)
+	return finished
+}
+
+// Observes once then propagates, checking ctx between propagation passes
+func (b *BaseModel) iterateOnce(ctx context.Context, sm Solver) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
 	finished := b.Observe(sm)
 
 	if finished {
-		return true
+		return true, nil
 	}
 
 	for sm.Propagate() {
-		// Empty loop
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
 	}
 
-	return false // Not finished yet
+	return false, nil // Not finished yet
 }
 
-func (b *BaseModel) Iterate(sm Collapser, iterations int) bool {
+func (b *BaseModel) Iterate(sm Solver, iterations int) bool {
+	finished, _ := b.IterateContext(context.Background(), sm, iterations)
+	return finished
+}
+
+// Same as Iterate but stops early with ctx.Err() once ctx is done, the
+// model is left in a state that can be rendered or iterated further
+func (b *BaseModel) IterateContext(ctx context.Context, sm Solver, iterations int) (bool, error) {
 	if !b.InitField {
 		sm.Clear()
 	}
 
 	for i := 0; i < iterations; i++ {
-		finished := b.IterateOnce(sm)
+		finished, err := b.iterateOnce(ctx, sm)
+		if err != nil {
+			return false, err
+		}
 		if finished {
-			return true
+			return true, nil
 		}
 	}
-	return false // Not finished yet
+	return false, nil // Not finished yet
+}
+
+func (baseModel *BaseModel) Generate(sm Solver) {
+	baseModel.GenerateContext(context.Background(), sm)
 }
 
-func (baseModel *BaseModel) Generate(sm Collapser) {
+// Same as Generate but stops early with ctx.Err() once ctx is done, the
+// model is left in a state that can be rendered or iterated further
+func (b *BaseModel) GenerateContext(ctx context.Context, sm Solver) error {
 	sm.Clear()
 	for {
-		finished := baseModel.IterateOnce(sm)
+		finished, err := b.iterateOnce(ctx, sm)
+		if err != nil {
+			return err
+		}
 		if finished {
-			return
+			return nil
 		}
 	}
 }
 
+// Returns whether pattern t can still fit (x, y)
+func (b *BaseModel) Allowed(x, y, t int) bool {
+	return b.wave.Get(x+y*b.Fmx, t)
+}
+
+// Returns how many patterns can still fit (x, y)
+func (b *BaseModel) Remaining(x, y int) int {
+	return b.wave.Count(x + y*b.Fmx)
+}
+
+// Returns a copy of the patterns that can still fit (x, y)
+func (b *BaseModel) Possible(x, y int) Bitset {
+	s := NewBitset(b.T)
+	copy(s, b.wave.Cell(x+y*b.Fmx))
+	return s
+}
+
 func (b *BaseModel) IsGenSuccess() bool {
 	return b.GenSuccess
 }
 
 func (baseModel *BaseModel) SetSeed(seed int64) {
-	baseModel.Rng = rand.New(rand.NewSource(seed)).Float64
+	baseModel.seedRng(seed, 0)
 	baseModel.RngSet = true
 }
 
-func (b *BaseModel) ClearBase(sm Collapser) {
-	for x := 0; x < b.Fmx; x++ {
-		for y := 0; y < b.Fmy; y++ {
-			for t := 0; t < b.T; t++ {
-				b.Wave[x][y][t] = true
-			}
-			b.Changes[x][y] = false
-		}
+// Enables undoing observations on contradiction, budget limits the
+// number of backtracks per generation, 0 for no limit. Backtracking
+// undoes one observation at a time, so without a limit a large output
+// with no solution near a contradiction can take exponential time
+func (b *BaseModel) SetBacktracking(budget int) {
+	b.Backtracking = true
+	b.BacktrackBudget = budget
+}
+
+// Seeded generator counting its draws, so a snapshot can record how far
+// along it is
+type countingRng struct {
+	seed  int64
+	draws int64
+	r     *rand.Rand
+}
+
+func (c *countingRng) Float64() float64 {
+	c.draws++
+	return c.r.Float64()
+}
+
+// Sets Rng to a generator seeded with seed that has already made draws
+// draws
+func (b *BaseModel) seedRng(seed, draws int64) {
+	b.rng = &countingRng{seed: seed, r: rand.New(rand.NewSource(seed))}
+	for b.rng.draws < draws {
+		b.rng.Float64()
 	}
+	b.Rng = b.rng.Float64
+}
+
+func (b *BaseModel) ClearBase(sm Solver) {
+	b.wave.Fill()
 	if !b.RngSet {
-		b.Rng = rand.New(rand.NewSource(time.Now().UnixNano())).Float64
+		b.seedRng(time.Now().UnixNano(), 0)
 	}
+	b.clearSupport()
+	b.clearEntropy(sm)
 	b.InitField = true
 	b.GenSuccess = false
+	b.Backtracks = 0
+	b.history = b.history[:0]
+	b.trail = b.trail[:0]
+
+	b.applyConstraints()
+	for sm.Propagate() {
+		// Empty loop
+	}
 }
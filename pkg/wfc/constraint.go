@@ -0,0 +1,74 @@
+package wfc
+
+import "fmt"
+
+// Patterns allowed at a cell before generation starts
+type constraint struct {
+	x       int
+	y       int
+	allowed Bitset
+}
+
+// Restricts (x, y) to the given patterns, kept across Clear and
+// propagated before the first observation
+func (b *BaseModel) ConstrainPatterns(x, y int, patterns ...int) error {
+	if err := b.checkCell(x, y); err != nil {
+		return err
+	}
+
+	allowed := NewBitset(b.T)
+	for _, t := range patterns {
+		if t < 0 || t >= b.T {
+			return fmt.Errorf("wfc: pattern %d out of range, want 0 to %d", t, b.T-1)
+		}
+		allowed.Add(t)
+	}
+
+	b.constraints = append(b.constraints, constraint{x, y, allowed})
+	return nil
+}
+
+// Forbids pattern t at (x, y), kept across Clear and propagated before
+// the first observation
+func (b *BaseModel) Ban(x, y, t int) error {
+	if err := b.checkCell(x, y); err != nil {
+		return err
+	}
+	if t < 0 || t >= b.T {
+		return fmt.Errorf("wfc: pattern %d out of range, want 0 to %d", t, b.T-1)
+	}
+
+	allowed := NewBitset(b.T)
+	for t2 := 0; t2 < b.T; t2++ {
+		if t2 != t {
+			allowed.Add(t2)
+		}
+	}
+
+	b.constraints = append(b.constraints, constraint{x, y, allowed})
+	return nil
+}
+
+// Removes every constraint, takes effect on the next Clear
+func (b *BaseModel) ClearConstraints() {
+	b.constraints = b.constraints[:0]
+}
+
+func (b *BaseModel) checkCell(x, y int) error {
+	if x < 0 || x >= b.Fmx || y < 0 || y >= b.Fmy {
+		return fmt.Errorf("wfc: cell (%d, %d) out of range, want (0, 0) to (%d, %d)", x, y, b.Fmx-1, b.Fmy-1)
+	}
+	return nil
+}
+
+// Bans every pattern the constraints do not allow
+func (b *BaseModel) applyConstraints() {
+	for _, c := range b.constraints {
+		cell := b.wave.Cell(c.x + c.y*b.Fmx)
+		for t := 0; t < b.T; t++ {
+			if cell.Has(t) && !c.allowed.Has(t) {
+				b.ban(c.x, c.y, t)
+			}
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package wfc
+
+import (
+	"container/heap"
+	"math"
+	"math/rand"
+)
+
+// Mixed into the seed of the tie breaking noise, so it does not repeat
+// the values Rng draws
+const noiseSalt = 0x5851f42d4c957f2d
+
+// Undecided cells ordered by priority, implements heap.Interface
+type cellHeap struct {
+	cells []int     // Heap of cell indices
+	pos   []int     // Position of each cell in cells, -1 if not in the heap
+	key   []float64 // Priority of each cell
+}
+
+func (h *cellHeap) Len() int {
+	return len(h.cells)
+}
+
+func (h *cellHeap) Less(i, j int) bool {
+	return h.key[h.cells[i]] < h.key[h.cells[j]]
+}
+
+func (h *cellHeap) Swap(i, j int) {
+	h.cells[i], h.cells[j] = h.cells[j], h.cells[i]
+	h.pos[h.cells[i]] = i
+	h.pos[h.cells[j]] = j
+}
+
+func (h *cellHeap) Push(x any) {
+	h.pos[x.(int)] = len(h.cells)
+	h.cells = append(h.cells, x.(int))
+}
+
+func (h *cellHeap) Pop() any {
+	i := h.cells[len(h.cells)-1]
+	h.cells = h.cells[:len(h.cells)-1]
+	h.pos[i] = -1
+	return i
+}
+
+// Allocates the per cell entropy bookkeeping
+func (b *BaseModel) initEntropy() {
+	cells := b.Fmx * b.Fmy
+
+	b.weightLogWeights = make([]float64, b.T)
+	b.sumOfWeights = 0.0
+	b.sumOfWeightLogWeights = 0.0
+	for t := 0; t < b.T; t++ {
+		b.weightLogWeights[t] = b.Stationary[t] * math.Log(b.Stationary[t])
+		b.sumOfWeights += b.Stationary[t]
+		b.sumOfWeightLogWeights += b.weightLogWeights[t]
+	}
+
+	b.sumsOfOnes = make([]int, cells)
+	b.sumsOfWeights = make([]float64, cells)
+	b.sumsOfWeightLogWeights = make([]float64, cells)
+	b.entropies = make([]float64, cells)
+	b.used = make([]int, b.T)
+	b.noise = make([]float64, cells)
+	b.frontier = cellHeap{
+		cells: make([]int, 0, cells),
+		pos:   make([]int, cells),
+		key:   make([]float64, cells),
+	}
+}
+
+// Resets every cell to allow all patterns and redraws the noise used
+// to break ties between cells of equal entropy
+func (b *BaseModel) clearEntropy(sm Checker) {
+	if len(b.sumsOfOnes) != b.Fmx*b.Fmy || len(b.weightLogWeights) != b.T {
+		b.initEntropy()
+	}
+
+	entropy := math.Log(b.sumOfWeights) - b.sumOfWeightLogWeights/b.sumOfWeights
+
+	// Drawn from a generator of its own so Rng is only used to choose
+	// patterns
+	var seed int64
+	if b.rng != nil {
+		seed = b.rng.seed
+	}
+	noise := rand.New(rand.NewSource(seed ^ noiseSalt))
+
+	for i := range b.sumsOfOnes {
+		b.sumsOfOnes[i] = b.T
+		b.sumsOfWeights[i] = b.sumOfWeights
+		b.sumsOfWeightLogWeights[i] = b.sumOfWeightLogWeights
+		b.entropies[i] = entropy
+		b.noise[i] = 0.000001 * noise.Float64()
+	}
+	b.contradiction = false
+
+	for t := range b.used {
+		b.used[t] = 0
+	}
+	if b.T == 1 {
+		b.used[0] = len(b.sumsOfOnes)
+	}
+
+	b.rebuildFrontier(sm)
+}
+
+// Fills the heap with every undecided cell
+func (b *BaseModel) rebuildFrontier(sm Checker) {
+	h := &b.frontier
+	h.cells = h.cells[:0]
+
+	for i := range b.sumsOfOnes {
+		h.pos[i] = -1
+		if b.sumsOfOnes[i] > 1 && !sm.OnBoundary(i%b.Fmx, i/b.Fmx) {
+			h.key[i] = b.priority(i)
+			h.pos[i] = len(h.cells)
+			h.cells = append(h.cells, i)
+		}
+	}
+
+	heap.Init(h)
+}
+
+// Removes the weight of pattern t from cell i, called once t is banned
+func (b *BaseModel) removeWeight(i, t int) {
+	b.sumsOfOnes[i]--
+	b.sumsOfWeights[i] -= b.Stationary[t]
+	b.sumsOfWeightLogWeights[i] -= b.weightLogWeights[t]
+
+	sum := b.sumsOfWeights[i]
+	b.entropies[i] = math.Log(sum) - b.sumsOfWeightLogWeights[i]/sum
+
+	switch b.sumsOfOnes[i] {
+	case 1:
+		b.used[b.wave.Cell(i).First()]++
+	case 0:
+		b.used[t]--
+		b.contradiction = true
+		if b.Observer != nil {
+			b.Observer.Contradiction(i%b.Fmx, i/b.Fmx)
+		}
+	}
+
+	h := &b.frontier
+	if h.pos[i] == -1 {
+		return
+	}
+	if b.sumsOfOnes[i] <= 1 {
+		heap.Remove(h, h.pos[i])
+	} else {
+		h.key[i] = b.priority(i)
+		heap.Fix(h, h.pos[i])
+	}
+}
+
+// Adds the weight of pattern t back to cell i, called once t is unbanned
+func (b *BaseModel) restoreWeight(sm Checker, i, t int) {
+	b.sumsOfOnes[i]++
+	b.sumsOfWeights[i] += b.Stationary[t]
+	b.sumsOfWeightLogWeights[i] += b.weightLogWeights[t]
+
+	sum := b.sumsOfWeights[i]
+	b.entropies[i] = math.Log(sum) - b.sumsOfWeightLogWeights[i]/sum
+
+	switch b.sumsOfOnes[i] {
+	case 1:
+		b.used[t]++
+		return
+	case 2:
+		// Undecided again, no longer counts as its other pattern
+		cell := b.wave.Cell(i)
+		cell.Remove(t)
+		b.used[cell.First()]--
+		cell.Add(t)
+	}
+
+	h := &b.frontier
+	h.key[i] = b.priority(i)
+	if h.pos[i] != -1 {
+		heap.Fix(h, h.pos[i])
+	} else if !sm.OnBoundary(i%b.Fmx, i/b.Fmx) {
+		heap.Push(h, i)
+	}
+}
+
+// Returns the priority of cell i under the current selector
+func (b *BaseModel) priority(i int) float64 {
+	if b.CellSelector == nil {
+		return MinEntropy{}.Priority(b, i%b.Fmx, i/b.Fmx)
+	}
+	return b.CellSelector.Priority(b, i%b.Fmx, i/b.Fmx)
+}
+
+// Returns the undecided cell with the lowest priority, ok is false once
+// every cell is decided
+func (b *BaseModel) nextCell() (x, y int, ok bool) {
+	if b.frontier.Len() == 0 {
+		return -1, -1, false
+	}
+	i := b.frontier.cells[0]
+	return i % b.Fmx, i / b.Fmx, true
+}
@@ -0,0 +1,51 @@
+package wfc
+
+import "fmt"
+
+// A data file could not be read
+type FileError struct {
+	Path string // Path of the data file
+	Err  error  // Underlying error
+}
+
+func (e *FileError) Error() string {
+	return fmt.Sprintf("wfc: reading %s: %v", e.Path, e.Err)
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// A data file could not be parsed
+type JSONError struct {
+	Path   string // Path of the data file
+	Field  string // Offending field, empty for syntax errors
+	Offset int64  // Byte offset of the error in the file
+	Err    error  // Underlying error
+}
+
+func (e *JSONError) Error() string {
+	if e.Field != "" {
+		return fmt.Sprintf("wfc: parsing %s: field %q: %v", e.Path, e.Field, e.Err)
+	}
+	return fmt.Sprintf("wfc: parsing %s at offset %d: %v", e.Path, e.Offset, e.Err)
+}
+
+func (e *JSONError) Unwrap() error {
+	return e.Err
+}
+
+// An image of a tile could not be loaded
+type TileError struct {
+	Tile string // Name of the tile
+	Path string // Path of the image
+	Err  error  // Underlying error
+}
+
+func (e *TileError) Error() string {
+	return fmt.Sprintf("wfc: tile %q: loading %s: %v", e.Tile, e.Path, e.Err)
+}
+
+func (e *TileError) Unwrap() error {
+	return e.Err
+}
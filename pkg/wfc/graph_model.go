@@ -0,0 +1,269 @@
+package wfc
+
+import (
+	"context"
+	"fmt"
+)
+
+// A constraint problem over a user supplied graph, every node takes one
+// of Labels and every edge only allows the label pairs of its kind
+type Graph struct {
+	Labels  []string               // Values a node can take
+	Weights []float64              // Weight of each label, nil for equal weights
+	Nodes   []GraphNode            //
+	Edges   []GraphEdge            //
+	Kinds   map[string][][2]string // Allowed (from, to) label pairs of each edge kind
+}
+
+type GraphNode struct {
+	Name       string   // Used in errors only
+	Candidates []string // Labels the node may take, nil for any
+}
+
+type GraphEdge struct {
+	From int    // Index into Nodes
+	To   int    // Index into Nodes
+	Kind string // Key into Kinds, pairs are read from the From node to the To node
+}
+
+// Undirected graphs list each pair both ways, or use this to add them
+func Symmetric(pairs [][2]string) [][2]string {
+	result := make([][2]string, 0, 2*len(pairs))
+	for _, p := range pairs {
+		result = append(result, p, [2]string{p[1], p[0]})
+	}
+	return result
+}
+
+// Graph connections, each node has a slot per direction holding the
+// neighbour across it or -1
+type GraphTopology struct {
+	Slots [][]int // [node][d]
+}
+
+func (g GraphTopology) Directions() int {
+	if len(g.Slots) == 0 {
+		return 0
+	}
+	return len(g.Slots[0])
+}
+
+func (g GraphTopology) Neighbour(x, y, d int) (int, int, bool) {
+	n := g.Slots[x][d]
+	return n, 0, n != -1
+}
+
+// Model solving a Graph, node i is the cell (i, 0)
+type GraphModel struct {
+	*BaseModel
+	Labels     []string // Value of each pattern (t)
+	NodeNames  []string // Name of each node
+	candidates []Bitset // Labels allowed at each node
+}
+
+// Edges are coloured so no node has two edges of the same kind, colour
+// and direction, which keeps one support count per edge end
+func NewGraphModel(g Graph) (*GraphModel, error) {
+	m := &GraphModel{
+		BaseModel: &BaseModel{
+			Fmx:        len(g.Nodes),
+			Fmy:        1,
+			T:          len(g.Labels),
+			Stationary: make([]float64, len(g.Labels)),
+		},
+		Labels: g.Labels,
+	}
+	if m.T == 0 || m.Fmx == 0 {
+		return nil, fmt.Errorf("wfc: graph needs at least one label and one node")
+	}
+
+	index := make(map[string]int)
+	for t, label := range g.Labels {
+		if _, ok := index[label]; ok {
+			return nil, fmt.Errorf("wfc: duplicate label %q", label)
+		}
+		index[label] = t
+		m.Stationary[t] = 1
+		if g.Weights != nil {
+			if t >= len(g.Weights) || g.Weights[t] <= 0 {
+				return nil, fmt.Errorf("wfc: label %q needs a positive weight", label)
+			}
+			m.Stationary[t] = g.Weights[t]
+		}
+	}
+
+	for i, node := range g.Nodes {
+		m.NodeNames = append(m.NodeNames, node.Name)
+		allowed := NewBitset(m.T)
+		if node.Candidates == nil {
+			for t := 0; t < m.T; t++ {
+				allowed.Add(t)
+			}
+		}
+		for _, label := range node.Candidates {
+			t, ok := index[label]
+			if !ok {
+				return nil, fmt.Errorf("wfc: node %d (%s): unknown label %q", i, node.Name, label)
+			}
+			allowed.Add(t)
+		}
+		m.candidates = append(m.candidates, allowed)
+	}
+
+	// Compatibility table of each kind [k][t1][t2], t1 at the From end
+	kinds := make(map[string]int)
+	tables := make([][][]bool, 0)
+	for i, e := range g.Edges {
+		if e.From < 0 || e.From >= m.Fmx || e.To < 0 || e.To >= m.Fmx {
+			return nil, fmt.Errorf("wfc: edge %d: node out of range, want 0 to %d", i, m.Fmx-1)
+		}
+		if _, ok := kinds[e.Kind]; ok {
+			continue
+		}
+		pairs, ok := g.Kinds[e.Kind]
+		if !ok {
+			return nil, fmt.Errorf("wfc: edge %d: unknown kind %q", i, e.Kind)
+		}
+
+		table := make([][]bool, m.T)
+		for t := range table {
+			table[t] = make([]bool, m.T)
+		}
+		for _, p := range pairs {
+			t1, ok1 := index[p[0]]
+			t2, ok2 := index[p[1]]
+			if !ok1 || !ok2 {
+				return nil, fmt.Errorf("wfc: kind %q: unknown label in pair %v", e.Kind, p)
+			}
+			table[t1][t2] = true
+		}
+
+		kinds[e.Kind] = len(tables)
+		tables = append(tables, table)
+	}
+
+	// Greedy edge colouring, an edge's colour is free at both its ends
+	colours := make([]int, len(g.Edges))
+	used := make([]map[int]bool, m.Fmx)
+	for i := range used {
+		used[i] = make(map[int]bool)
+	}
+	count := 0
+	for i, e := range g.Edges {
+		c := 0
+		for used[e.From][c] || used[e.To][c] {
+			c++
+		}
+		used[e.From][c] = true
+		used[e.To][c] = true
+		colours[i] = c
+		if c+1 > count {
+			count = c + 1
+		}
+	}
+
+	// Direction ((kind*count + colour)*2 + backwards)
+	directions := len(tables) * count * 2
+	slots := make([][]int, m.Fmx)
+	for i := range slots {
+		slots[i] = make([]int, directions)
+		for d := range slots[i] {
+			slots[i][d] = -1
+		}
+	}
+	for i, e := range g.Edges {
+		d := (kinds[e.Kind]*count + colours[i]) * 2
+		slots[e.From][d] = e.To
+		slots[e.To][d+1] = e.From
+	}
+
+	adjacency := make([][][]int, directions)
+	for d := range adjacency {
+		table := tables[d/2/count]
+		adjacency[d] = make([][]int, m.T)
+		for t1 := 0; t1 < m.T; t1++ {
+			adjacency[d][t1] = make([]int, 0)
+			for t2 := 0; t2 < m.T; t2++ {
+				if (d%2 == 0 && table[t1][t2]) || (d%2 == 1 && table[t2][t1]) {
+					adjacency[d][t1] = append(adjacency[d][t1], t2)
+				}
+			}
+		}
+	}
+
+	m.wave = NewWave(m.Fmx, m.T)
+	m.SetTopology(GraphTopology{slots}, adjacency)
+
+	return m, nil
+}
+
+func (m *GraphModel) OnBoundary(x, y int) bool {
+	return false
+}
+
+func (m *GraphModel) Propagate() bool {
+	return m.PropagateBase(m)
+}
+
+// Restricts every node to its candidates and removes labels no label
+// of an existing neighbour allows, which propagation alone never bans
+func (m *GraphModel) Clear() {
+	m.ClearBase(m)
+
+	directions := m.Topology.Directions()
+	for x := 0; x < m.Fmx; x++ {
+		for t := 0; t < m.T; t++ {
+			if !m.candidates[x].Has(t) {
+				m.ban(x, 0, t)
+				continue
+			}
+			for d := 0; d < directions; d++ {
+				// The neighbour across slot d reaches x through d^1
+				if _, _, ok := m.Topology.Neighbour(x, 0, d); ok && m.initialSupport[t*directions+d^1] == 0 {
+					m.ban(x, 0, t)
+					break
+				}
+			}
+		}
+	}
+
+	for m.Propagate() {
+		// Empty loop
+	}
+}
+
+// Puts the model back in the state of a snapshot, see Snapshot
+func (m *GraphModel) Restore(s *Snapshot) error {
+	return m.BaseModel.Restore(m, s)
+}
+
+// Returns the label of every node, empty if undecided
+func (m *GraphModel) Assignment() []string {
+	result := make([]string, m.Fmx)
+	for x := range result {
+		if m.Remaining(x, 0) == 1 {
+			result[x] = m.Labels[m.wave.Cell(x).First()]
+		}
+	}
+	return result
+}
+
+func (m *GraphModel) Iterate(iterations int) (bool, bool) {
+	finished := m.BaseModel.Iterate(m, iterations)
+	return finished, m.IsGenSuccess()
+}
+
+func (m *GraphModel) Generate() bool {
+	m.BaseModel.Generate(m)
+	return m.IsGenSuccess()
+}
+
+func (m *GraphModel) IterateContext(ctx context.Context, iterations int) (bool, bool, error) {
+	finished, err := m.BaseModel.IterateContext(ctx, m, iterations)
+	return finished, m.IsGenSuccess(), err
+}
+
+func (m *GraphModel) GenerateContext(ctx context.Context) (bool, error) {
+	err := m.BaseModel.GenerateContext(ctx, m)
+	return m.IsGenSuccess(), err
+}
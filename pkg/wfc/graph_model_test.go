@@ -0,0 +1,99 @@
+package wfc
+
+import (
+	"testing"
+)
+
+// Rooms joined by doors, the boss only guards treasure
+func dungeonGraph() Graph {
+	g := Graph{
+		Labels:  []string{"entrance", "corridor", "room", "treasure", "boss"},
+		Weights: []float64{1, 3, 3, 1, 1},
+		Kinds: map[string][][2]string{
+			"door": Symmetric([][2]string{
+				{"entrance", "corridor"},
+				{"corridor", "corridor"},
+				{"corridor", "room"},
+				{"room", "room"},
+				{"room", "boss"},
+			}),
+			"guards": {{"boss", "treasure"}},
+		},
+	}
+
+	names := []string{"start", "hub", "a", "b", "c", "d", "e", "lair", "hoard"}
+	for _, name := range names {
+		g.Nodes = append(g.Nodes, GraphNode{Name: name})
+	}
+	g.Nodes[0].Candidates = []string{"entrance"}
+	g.Nodes[8].Candidates = []string{"treasure"}
+
+	g.Edges = []GraphEdge{{0, 1, "door"}, {7, 8, "guards"}, {6, 7, "door"}}
+	for i := 2; i <= 6; i++ {
+		g.Edges = append(g.Edges, GraphEdge{1, i, "door"})
+	}
+	g.Edges = append(g.Edges, GraphEdge{2, 3, "door"}, GraphEdge{3, 4, "door"})
+	return g
+}
+
+func TestGraphModelSatisfiesEdges(t *testing.T) {
+	g := dungeonGraph()
+	allowed := make(map[string]map[[2]string]bool)
+	for kind, pairs := range g.Kinds {
+		allowed[kind] = make(map[[2]string]bool)
+		for _, p := range pairs {
+			allowed[kind][p] = true
+		}
+	}
+
+	for seed := int64(0); seed < 20; seed++ {
+		model, err := NewGraphModel(g)
+		if err != nil {
+			t.Fatal(err)
+		}
+		model.SetSeed(seed)
+		if !model.Generate() {
+			t.Fatalf("Failed to generate with seed %d.", seed)
+		}
+
+		labels := model.Assignment()
+		if labels[0] != "entrance" || labels[8] != "treasure" || labels[7] != "boss" {
+			t.Fatalf("Expected entrance, boss and treasure to be kept, got %v.", labels)
+		}
+		for _, e := range g.Edges {
+			if p := [2]string{labels[e.From], labels[e.To]}; !allowed[e.Kind][p] {
+				t.Fatalf("Edge %s -> %s (%s) got illegal labels %v.", model.NodeNames[e.From], model.NodeNames[e.To], e.Kind, p)
+			}
+		}
+	}
+}
+
+func TestGraphModelContradiction(t *testing.T) {
+	g := dungeonGraph()
+	g.Nodes[1].Candidates = []string{"boss"}
+
+	model, err := NewGraphModel(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	model.SetSeed(0)
+	if model.Generate() {
+		t.Fatalf("Expected a boss next to the entrance to fail, got %v.", model.Assignment())
+	}
+}
+
+func TestGraphModelErrors(t *testing.T) {
+	for name, edit := range map[string]func(*Graph){
+		"unknown kind":      func(g *Graph) { g.Edges[0].Kind = "window" },
+		"node out of range": func(g *Graph) { g.Edges[0].To = len(g.Nodes) },
+		"unknown candidate": func(g *Graph) { g.Nodes[0].Candidates = []string{"moat"} },
+		"unknown pair":      func(g *Graph) { g.Kinds["guards"] = [][2]string{{"boss", "gold"}} },
+		"duplicate label":   func(g *Graph) { g.Labels[1] = "room" },
+	} {
+		g := dungeonGraph()
+		edit(&g)
+		if _, err := NewGraphModel(g); err == nil {
+			t.Errorf("Expected an error for %s.", name)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package wfc
+
+import (
+	"fmt"
+)
+
+type RawHexData struct {
+	Path       string            `json:"path"`       // Path to tiles
+	Unique     bool              `json:"unique"`     // Default to false
+	TileWidth  int               `json:"tileWidth"`  // Width of the hexagon, about 0.866 of its height
+	TileHeight int               `json:"tileHeight"` // Height of the hexagon, point to point
+	Tiles      []RawTile         `json:"tiles"`      // Symmetry is one of X, Y, I or L, see hexSymmetry
+	Neighbours []RawHexNeighbour `json:"neighbors"`  //
+}
+
+// Information on which tiles can touch across an edge
+type RawHexNeighbour struct {
+	Tile         string `json:"tile"`        // Mathces Tile.Name
+	TileNum      int    `json:"tileNum"`     // Default to 0
+	Edge         int    `json:"edge"`        // Edge of the tile, 0 for east going anticlockwise
+	Neighbour    string `json:"neighbor"`    // Mathces Tile.Name
+	NeighbourNum int    `json:"neighborNum"` // Default to 0
+}
+
+type HexData struct {
+	Unique     bool
+	TileWidth  int
+	TileHeight int
+	Tiles      []Tile
+	Neighbors  []HexNeighbour
+}
+
+type HexNeighbour struct {
+	Tile         string
+	TileNum      int
+	Edge         int
+	Neighbour    string
+	NeighbourNum int
+}
+
+// Same as LoadHexData but panics on error
+func MakeHexData(path string, file string) HexData {
+	data, err := LoadHexData(path, file)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// Reads the json data file of a hex model and the tile images it
+// references, errors are a *FileError, *JSONError or *TileError
+func LoadHexData(path string, file string) (HexData, error) {
+	var rd RawHexData
+	if err := readJSONData(path+file, &rd); err != nil {
+		return HexData{}, err
+	}
+
+	tiles, err := loadRawTiles(path+rd.Path, rd.Tiles, rd.Unique)
+	if err != nil {
+		return HexData{}, err
+	}
+
+	neighbours := make([]HexNeighbour, len(rd.Neighbours))
+	for i, rn := range rd.Neighbours {
+		neighbours[i] = HexNeighbour(rn)
+	}
+
+	return HexData{
+		Unique:     rd.Unique,
+		TileWidth:  rd.TileWidth,
+		TileHeight: rd.TileHeight,
+		Tiles:      tiles,
+		Neighbors:  neighbours,
+	}, nil
+}
+
+// Names of the hex edges, where the neighbour sits
+var hexEdgeNames = [6]string{"east", "north east", "north west", "west", "south west", "south east"}
+
+// Checks the data for problems NewHexTiledModel would silently accept
+// or panic on, reporting all of them at once as a *ValidationError
+func (d HexData) Validate() error {
+	v := newValidator()
+	v.size(d.TileWidth, d.TileHeight)
+
+	names := make([]string, len(d.Tiles))
+	for i, tile := range d.Tiles {
+		field := fmt.Sprintf("tiles[%d]", i)
+		names[i] = tile.Name
+		cardinality, ok := hexSymmetry(tile.Sym)
+		v.tile(field, tile.Name, tile.Weight, tile.Sym, cardinality, ok, "X, Y, I, L")
+		v.images(field, tile, d.Unique, d.TileWidth, d.TileHeight)
+	}
+
+	valid := make([]HexNeighbour, 0, len(d.Neighbors))
+	for i, n := range d.Neighbors {
+		field := fmt.Sprintf("neighbors[%d]", i)
+		tile := v.side(field+".tile", n.Tile, n.TileNum, 6)
+		neighbour := v.side(field+".neighbor", n.Neighbour, n.NeighbourNum, 6)
+		edge := n.Edge >= 0 && n.Edge < 6
+		if !edge {
+			v.report(n.Tile, field+".edge", "%d out of range, want 0 to 5", n.Edge)
+		}
+		if tile && neighbour && edge {
+			valid = append(valid, n)
+		}
+	}
+
+	action, first := hexActions(d.Tiles)
+	propagator := hexPropagator(action, first, valid)
+
+	where := make([]string, len(hexEdgeNames))
+	for dir, name := range hexEdgeNames {
+		where[dir] = "to the " + name
+	}
+	v.stranded(names, first, where, func(dir, t int) bool {
+		for t2 := range action {
+			if propagator[dir][t2][t] {
+				return true
+			}
+		}
+		return false
+	})
+
+	return v.err()
+}
@@ -0,0 +1,287 @@
+package wfc
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"math"
+)
+
+// Tiled model over a HexGrid of pointy topped hexagons, tiles turn in
+// sixths and are drawn with odd rows pushed half a tile right
+type HexTiledModel struct {
+	*BaseModel
+	TileWidth  int
+	TileHeight int
+	Tiles      []TilePattern
+	TileOf     []int      // Index into the data tiles of the tile each pattern (t) is a variant of
+	TileNames  []string   // Name of each data tile
+	Propagator [][][]bool // Table of which variants (t2) may touch edge d of a variant (t1) [d][t2][t1]
+}
+
+func NewHexTiledModel(data HexData, width, height int, periodic bool) *HexTiledModel {
+	m := &HexTiledModel{
+		BaseModel: &BaseModel{
+			Fmx:        width,
+			Fmy:        height,
+			Periodic:   periodic,
+			Stationary: make([]float64, 0),
+		},
+		TileWidth:  data.TileWidth,
+		TileHeight: data.TileHeight,
+		Tiles:      make([]TilePattern, 0),
+	}
+
+	action, first := hexActions(data.Tiles)
+
+	tile := func(img image.Image) TilePattern {
+		result := make(TilePattern, m.TileWidth*m.TileHeight)
+		for y := 0; y < m.TileHeight; y++ {
+			for x := 0; x < m.TileWidth; x++ {
+				result[x+y*m.TileWidth] = img.At(x, y)
+			}
+		}
+		return result
+	}
+
+	for i, current := range data.Tiles {
+		cardinality, _ := hexSymmetry(current.Sym)
+		m.TileNames = append(m.TileNames, current.Name)
+		start := len(m.Tiles)
+
+		if data.Unique {
+			for t := 0; t < cardinality; t++ {
+				m.Tiles = append(m.Tiles, tile(current.Variants[t]))
+			}
+		} else {
+			m.Tiles = append(m.Tiles, tile(current.Variants[0]))
+			for t := 1; t < cardinality; t++ {
+				m.Tiles = append(m.Tiles, m.rotate(m.Tiles[start+t-1]))
+			}
+		}
+
+		for t := 0; t < cardinality; t++ {
+			m.Stationary = append(m.Stationary, current.Weight)
+			m.TileOf = append(m.TileOf, i)
+		}
+	}
+
+	m.T = len(action)
+	m.Propagator = hexPropagator(action, first, data.Neighbors)
+
+	m.wave = NewWave(m.Fmx*m.Fmy, m.T)
+
+	m.SetTopology(HexGrid{m.Fmx, m.Fmy, m.Periodic}, tileAdjacency(m.Propagator))
+
+	return m
+}
+
+// Turns a tile a sixth anticlockwise about its centre, pixels turned in
+// from outside the image are transparent
+func (m *HexTiledModel) rotate(p TilePattern) TilePattern {
+	sin, cos := math.Sincos(math.Pi / 3)
+	cx := float64(m.TileWidth-1) / 2
+	cy := float64(m.TileHeight-1) / 2
+
+	result := make(TilePattern, len(p))
+	for y := 0; y < m.TileHeight; y++ {
+		for x := 0; x < m.TileWidth; x++ {
+			dx, dy := float64(x)-cx, float64(y)-cy
+			sx := int(math.Round(dx*cos - dy*sin + cx))
+			sy := int(math.Round(dx*sin + dy*cos + cy))
+			if sx < 0 || sx >= m.TileWidth || sy < 0 || sy >= m.TileHeight {
+				result[x+y*m.TileWidth] = color.RGBA{}
+				continue
+			}
+			result[x+y*m.TileWidth] = p[sx+sy*m.TileWidth]
+		}
+	}
+	return result
+}
+
+// Returns the number of distinct turns of a hex symmetry class, X (or
+// "") looks the same at every turn, Y every third of a turn, I every
+// half turn and L never, ok is false for unknown classes which are
+// treated as X
+func hexSymmetry(sym string) (cardinality int, ok bool) {
+	switch sym {
+	case "X", "":
+		return 1, true
+	case "Y":
+		return 2, true
+	case "I":
+		return 3, true
+	case "L":
+		return 6, true
+	}
+	return 1, false
+}
+
+// Builds the table of variant turns [variant][sixths], the first variant
+// of every tile is indexed by name
+func hexActions(tiles []Tile) ([][]int, map[string]int) {
+	first := make(map[string]int)
+	action := make([][]int, 0)
+
+	for _, current := range tiles {
+		cardinality, _ := hexSymmetry(current.Sym)
+
+		T := len(action)
+		first[current.Name] = T
+
+		for t := 0; t < cardinality; t++ {
+			row := make([]int, 6)
+			for r := range row {
+				row[r] = T + (t+r)%cardinality
+			}
+			action = append(action, row)
+		}
+	}
+
+	return action, first
+}
+
+// Builds the table of which variants (t2) may touch edge d of a variant
+// (t1) [d][t2][t1], every rule holds at every turn
+func hexPropagator(action [][]int, first map[string]int, neighbours []HexNeighbour) [][][]bool {
+	T := len(action)
+	propagator := make([][][]bool, 6)
+	for d := 0; d < 6; d++ {
+		propagator[d] = make([][]bool, T)
+		for t := 0; t < T; t++ {
+			propagator[d][t] = make([]bool, T)
+		}
+	}
+
+	for _, n := range neighbours {
+		a := action[first[n.Tile]][n.TileNum]
+		b := action[first[n.Neighbour]][n.NeighbourNum]
+		for r := 0; r < 6; r++ {
+			propagator[(n.Edge+r)%6][action[b][r]][action[a][r]] = true
+			propagator[(n.Edge+r+3)%6][action[a][r]][action[b][r]] = true
+		}
+	}
+
+	return propagator
+}
+
+func (m *HexTiledModel) OnBoundary(x, y int) bool {
+	return false
+}
+
+func (m *HexTiledModel) Propagate() bool {
+	return m.PropagateBase(m)
+}
+
+func (m *HexTiledModel) Clear() {
+	m.ClearBase(m)
+}
+
+// Puts the model back in the state of a snapshot, see Snapshot
+func (m *HexTiledModel) Restore(s *Snapshot) error {
+	return m.BaseModel.Restore(m, s)
+}
+
+// Size of the rendered image, rows overlap by a quarter of a tile
+func (m *HexTiledModel) imageSize() (int, int) {
+	width := m.Fmx * m.TileWidth
+	if m.Fmy > 1 {
+		width += m.TileWidth / 2
+	}
+	return width, m.TileHeight + (m.Fmy-1)*m.rowStep()
+}
+
+func (m *HexTiledModel) rowStep() int {
+	return m.TileHeight - m.TileHeight/4
+}
+
+// Draws every cell with the colour of each of its pixels, transparent
+// pixels are skipped so the corners of neighbouring rows show through
+func (m *HexTiledModel) render(pixel func(x, y, xt, yt int) color.Color) image.Image {
+	width, height := m.imageSize()
+	output := make([][]color.Color, width)
+	for i := range output {
+		output[i] = make([]color.Color, height)
+		for j := range output[i] {
+			output[i][j] = color.RGBA{}
+		}
+	}
+
+	for y := 0; y < m.Fmy; y++ {
+		left := (y & 1) * (m.TileWidth / 2)
+		top := y * m.rowStep()
+		for x := 0; x < m.Fmx; x++ {
+			for yt := 0; yt < m.TileHeight; yt++ {
+				for xt := 0; xt < m.TileWidth; xt++ {
+					c := pixel(x, y, xt, yt)
+					if c == nil {
+						continue
+					}
+					if _, _, _, a := c.RGBA(); a == 0 {
+						continue
+					}
+					output[left+x*m.TileWidth+xt][top+yt] = c
+				}
+			}
+		}
+	}
+	return GeneratedImage{output}
+}
+
+func (m *HexTiledModel) RenderCompleteImage() image.Image {
+	return m.render(func(x, y, xt, yt int) color.Color {
+		t := m.wave.Cell(x + y*m.Fmx).First()
+		if t == -1 {
+			return nil
+		}
+		return m.Tiles[t][xt+yt*m.TileWidth]
+	})
+}
+
+func (m *HexTiledModel) RenderIncompleteImage() image.Image {
+	return m.render(func(x, y, xt, yt int) color.Color {
+		sum, sR, sG, sB, sA := 0.0, 0.0, 0.0, 0.0, 0.0
+		for t := 0; t < m.T; t++ {
+			if !m.Allowed(x, y, t) {
+				continue
+			}
+			r, g, b, a := m.Tiles[t][xt+yt*m.TileWidth].RGBA()
+			sR += float64(r) * m.Stationary[t]
+			sG += float64(g) * m.Stationary[t]
+			sB += float64(b) * m.Stationary[t]
+			sA += float64(a) * m.Stationary[t]
+			sum += m.Stationary[t]
+		}
+		if sum == 0 {
+			return nil
+		}
+		return color.RGBA64{uint16(sR / sum), uint16(sG / sum), uint16(sB / sum), uint16(sA / sum)}
+	})
+}
+
+func (m *HexTiledModel) Render() image.Image {
+	if m.IsGenSuccess() {
+		return m.RenderCompleteImage()
+	}
+	return m.RenderIncompleteImage()
+}
+
+func (m *HexTiledModel) Iterate(iterations int) (image.Image, bool, bool) {
+	finished := m.BaseModel.Iterate(m, iterations)
+	return m.Render(), finished, m.IsGenSuccess()
+}
+
+func (m *HexTiledModel) Generate() (image.Image, bool) {
+	m.BaseModel.Generate(m)
+	return m.Render(), m.IsGenSuccess()
+}
+
+func (m *HexTiledModel) IterateContext(ctx context.Context, iterations int) (image.Image, bool, bool, error) {
+	finished, err := m.BaseModel.IterateContext(ctx, m, iterations)
+	return m.Render(), finished, m.IsGenSuccess(), err
+}
+
+func (m *HexTiledModel) GenerateContext(ctx context.Context) (image.Image, bool, error) {
+	err := m.BaseModel.GenerateContext(ctx, m)
+	return m.Render(), m.IsGenSuccess(), err
+}
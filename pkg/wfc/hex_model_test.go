@@ -0,0 +1,56 @@
+package wfc
+
+import (
+	"testing"
+)
+
+func TestHexTiledNeighboursAgree(t *testing.T) {
+	data := MakeHexData("../../internal/input/", "hex_data.json")
+	if err := data.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	model := NewHexTiledModel(data, 12, 10, false)
+	model.SetSeed(1)
+	model.SetBacktracking(0)
+	outputImg, success := model.Generate()
+	if !success {
+		t.Fatal("Failed to generate image.")
+	}
+
+	// 12 tiles and half a tile across, 10 rows overlapping by a quarter
+	if size := outputImg.Bounds().Size(); size.X != 12*28+14 || size.Y != 32+9*24 {
+		t.Fatalf("Unexpected image size %v.", size)
+	}
+
+	state := model.State()
+	for x := 0; x < model.Fmx; x++ {
+		for y := 0; y < model.Fmy; y++ {
+			for d := 0; d < 6; d++ {
+				x2, y2, ok := model.Topology.Neighbour(x, y, d)
+				if ok && !model.Propagator[d][state[x2][y2]][state[x][y]] {
+					t.Fatalf("Illegal neighbour to the %s of (%d, %d).", hexEdgeNames[d], x, y)
+				}
+			}
+		}
+	}
+}
+
+func TestHexGridNeighboursAreMutual(t *testing.T) {
+	for _, periodic := range []bool{false, true} {
+		g := HexGrid{Width: 5, Height: 4, Periodic: periodic}
+		for x := 0; x < g.Width; x++ {
+			for y := 0; y < g.Height; y++ {
+				for d := 0; d < 6; d++ {
+					x2, y2, ok := g.Neighbour(x, y, d)
+					if !ok {
+						continue
+					}
+					if x3, y3, ok := g.Neighbour(x2, y2, (d+3)%6); !ok || x3 != x || y3 != y {
+						t.Fatalf("Direction %d from (%d, %d) does not lead back.", d, x, y)
+					}
+				}
+			}
+		}
+	}
+}
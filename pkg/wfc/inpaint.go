@@ -0,0 +1,70 @@
+package wfc
+
+import (
+	"context"
+	"image"
+	"image/color"
+)
+
+// Returns the pattern of every decided cell [x][y], -1 if undecided
+func (b *BaseModel) State() [][]int {
+	state := make([][]int, b.Fmx)
+	for x := 0; x < b.Fmx; x++ {
+		state[x] = make([]int, b.Fmy)
+		for y := 0; y < b.Fmy; y++ {
+			state[x][y] = -1
+			if b.Remaining(x, y) == 1 {
+				state[x][y] = b.wave.Cell(x + y*b.Fmx).First()
+			}
+		}
+	}
+	return state
+}
+
+// Clears the model and fixes every cell outside region to its pattern
+// in state, then observes the cells inside region until finished.
+// Region is in cells, undecided (-1) cells in state are left free
+func (b *BaseModel) InpaintContext(ctx context.Context, sm Solver, state [][]int, region image.Rectangle) error {
+	sm.Clear()
+
+	for x := 0; x < b.Fmx && x < len(state); x++ {
+		for y := 0; y < b.Fmy && y < len(state[x]); y++ {
+			t := state[x][y]
+			if t < 0 || t >= b.T || image.Pt(x, y).In(region) {
+				continue
+			}
+			for t2 := 0; t2 < b.T; t2++ {
+				if t2 != t {
+					b.ban(x, y, t2)
+				}
+			}
+		}
+	}
+
+	for sm.Propagate() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+
+	for {
+		finished, err := b.iterateOnce(ctx, sm)
+		if err != nil {
+			return err
+		}
+		if finished {
+			return nil
+		}
+	}
+}
+
+func (b *BaseModel) Inpaint(sm Solver, state [][]int, region image.Rectangle) {
+	b.InpaintContext(context.Background(), sm, state, region)
+}
+
+// Compares colours by value rather than by type
+func sameColor(c1, c2 color.Color) bool {
+	r1, g1, b1, a1 := c1.RGBA()
+	r2, g2, b2, a2 := c2.RGBA()
+	return r1 == r2 && g1 == g2 && b1 == b2 && a1 == a2
+}
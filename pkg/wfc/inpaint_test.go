@@ -0,0 +1,90 @@
+package wfc
+
+import (
+	"image"
+	"testing"
+	"wfc/pkg/utils"
+)
+
+func TestTiledInpaintKeepsOutside(t *testing.T) {
+	data := MakeTiledData("../../internal/input/", "castle_data.json")
+	model := NewTiledModel(data, 20, 20, false)
+	model.SetSeed(3)
+	img, success := model.Generate()
+	if !success {
+		t.Fatal("Failed to generate image on the first try.")
+	}
+	before := model.State()
+
+	state, err := model.StateFromImage(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for x := range state {
+		for y := range state[x] {
+			if !sameTile(model.Tiles[state[x][y]], model.Tiles[before[x][y]]) {
+				t.Fatalf("StateFromImage at (%d, %d) = %d, want a tile drawn as %d", x, y, state[x][y], before[x][y])
+			}
+		}
+	}
+
+	region := image.Rect(5, 5, 10, 10)
+	model.SetSeed(2)
+	if _, success := model.Inpaint(before, region); !success {
+		t.Fatal("Inpainting ran into a contradiction.")
+	}
+	after := model.State()
+	for x := range after {
+		for y := range after[x] {
+			if !image.Pt(x, y).In(region) && after[x][y] != before[x][y] {
+				t.Fatalf("Cell (%d, %d) outside the region changed from %d to %d", x, y, before[x][y], after[x][y])
+			}
+		}
+	}
+}
+
+func sameTile(a, b TilePattern) bool {
+	for i := range a {
+		if !sameColor(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestOverlappingInpaintImageKeepsOutside(t *testing.T) {
+	inputImg, err := utils.LoadImage("../../internal/input/flowers.png")
+	if err != nil {
+		panic(err)
+	}
+
+	for _, periodic := range []bool{false, true} {
+		model := NewOverlappingModel(inputImg, 3, 48, 48, true, periodic, 2, true)
+		model.SetSeed(8)
+		img, success := model.Generate()
+		if !success {
+			t.Fatalf("Failed to generate image on the first try, periodic %v.", periodic)
+		}
+
+		// Inside, and touching the top left corner where the patterns
+		// of a periodic output wrap around, and the bottom right corner
+		for _, rect := range []image.Rectangle{image.Rect(20, 20, 26, 26), image.Rect(0, 0, 5, 5), image.Rect(43, 43, 48, 48)} {
+			model.SetSeed(2)
+			out, success, err := model.InpaintImage(img, rect)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !success {
+				t.Fatalf("Inpainting %v ran into a contradiction, periodic %v.", rect, periodic)
+			}
+
+			for x := 0; x < 48; x++ {
+				for y := 0; y < 48; y++ {
+					if !image.Pt(x, y).In(rect) && !sameColor(out.At(x, y), img.At(x, y)) {
+						t.Fatalf("Pixel (%d, %d) outside %v changed, periodic %v.", x, y, rect, periodic)
+					}
+				}
+			}
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package wfc
 
-import "image"
+import (
+	"context"
+	"image"
+)
 
 type Iterator interface {
 	Iterate(iterations int) (image.Image, bool, bool)
@@ -10,6 +13,14 @@ type Generator interface {
 	Generate() (image.Image, bool)
 }
 
+type ContextIterator interface {
+	IterateContext(ctx context.Context, iterations int) (image.Image, bool, bool, error)
+}
+
+type ContextGenerator interface {
+	GenerateContext(ctx context.Context) (image.Image, bool, error)
+}
+
 type Checker interface {
 	OnBoundary(x int, y int) bool
 }
@@ -22,10 +33,15 @@ type Clearer interface {
 	Clear()
 }
 
-type Collapser interface {
-	Iterator
-	Generator
+// What BaseModel needs from the model embedding it
+type Solver interface {
 	Checker
 	Propagater
 	Clearer
 }
+
+type Collapser interface {
+	Iterator
+	Generator
+	Solver
+}
@@ -0,0 +1,23 @@
+package wfc
+
+// Receives the events of a generation as they happen, calls are made
+// from inside Iterate and Generate so they should return quickly. Clear
+// allows every pattern everywhere again without any event
+type Observer interface {
+	CellObserved(x, y, t int)  // (x, y) was decided as pattern t
+	PatternBanned(x, y, t int) // Pattern t can no longer fit (x, y)
+	Contradiction(x, y int)    // (x, y) has run out of patterns
+	Finished(success bool)     // Generation is over
+}
+
+// Observers that also implement this are told when backtracking gives
+// a banned pattern back to a cell
+type BacktrackObserver interface {
+	Observer
+	PatternRestored(x, y, t int)
+}
+
+// Sets the observer receiving generation events, nil for none
+func (b *BaseModel) SetObserver(observer Observer) {
+	b.Observer = observer
+}
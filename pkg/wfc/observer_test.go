@@ -0,0 +1,84 @@
+package wfc
+
+import (
+	"testing"
+)
+
+// Rebuilds the wave from events alone
+type replayObserver struct {
+	allowed        [][]map[int]bool
+	observed       int
+	contradictions int
+	restored       int
+	finished       []bool
+}
+
+func newReplayObserver(width, height, T int) *replayObserver {
+	o := &replayObserver{allowed: make([][]map[int]bool, width)}
+	for x := range o.allowed {
+		o.allowed[x] = make([]map[int]bool, height)
+		for y := range o.allowed[x] {
+			o.allowed[x][y] = make(map[int]bool)
+			for t := 0; t < T; t++ {
+				o.allowed[x][y][t] = true
+			}
+		}
+	}
+	return o
+}
+
+func (o *replayObserver) CellObserved(x, y, t int) {
+	o.observed++
+}
+
+func (o *replayObserver) PatternBanned(x, y, t int) {
+	delete(o.allowed[x][y], t)
+}
+
+func (o *replayObserver) Contradiction(x, y int) {
+	o.contradictions++
+}
+
+func (o *replayObserver) Finished(success bool) {
+	o.finished = append(o.finished, success)
+}
+
+func (o *replayObserver) PatternRestored(x, y, t int) {
+	o.restored++
+	o.allowed[x][y][t] = true
+}
+
+func TestObserverSeesEveryChange(t *testing.T) {
+	data := MakeTiledData("../../internal/input/", "castle_data.json")
+	model := NewTiledModel(data, 20, 20, false)
+	model.SetSeed(1)
+	model.SetBacktracking(0)
+
+	o := newReplayObserver(model.Fmx, model.Fmy, model.T)
+	model.SetObserver(o)
+	if _, success := model.Generate(); !success {
+		t.Fatal("Failed to generate image with backtracking.")
+	}
+	if model.Backtracks == 0 {
+		t.Fatal("Expected at least one backtrack.")
+	}
+
+	if len(o.finished) != 1 || !o.finished[0] {
+		t.Fatalf("Expected one successful Finished event, got %v.", o.finished)
+	}
+	if o.contradictions == 0 || o.restored == 0 {
+		t.Fatalf("Expected contradictions and restored patterns, got %d and %d.", o.contradictions, o.restored)
+	}
+	if o.observed == 0 {
+		t.Fatal("Expected CellObserved events.")
+	}
+
+	state := model.State()
+	for x := range state {
+		for y := range state[x] {
+			if len(o.allowed[x][y]) != 1 || !o.allowed[x][y][state[x][y]] {
+				t.Fatalf("Events leave %v at (%d, %d), want only %d.", o.allowed[x][y], x, y, state[x][y])
+			}
+		}
+	}
+}
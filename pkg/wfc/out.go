@@ -17,9 +17,9 @@ func (g GeneratedImage) Bounds() image.Rectangle {
 	if len(g.data) < 1 {
 		return image.Rect(0, 0, 0, 0)
 	}
-	return image.Rect(0, 0, len(g.data[0]), len(g.data))
+	return image.Rect(0, 0, len(g.data), len(g.data[0]))
 }
 
 func (g GeneratedImage) At(x, y int) color.Color {
 	return g.data[x][y]
-}
\ No newline at end of file
+}
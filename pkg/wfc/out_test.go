@@ -0,0 +1,19 @@
+package wfc
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestGeneratedImageBoundsNonSquare(t *testing.T) {
+	// Columns of pixels, indexed by x then y
+	data := make([][]color.Color, 3)
+	for x := range data {
+		data[x] = make([]color.Color, 2)
+	}
+
+	if got := (GeneratedImage{data}).Bounds(); got != image.Rect(0, 0, 3, 2) {
+		t.Fatalf("Expected bounds of a 3x2 image, got %v.", got)
+	}
+}
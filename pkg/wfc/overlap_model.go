@@ -1,6 +1,8 @@
 package wfc
 
 import (
+	"context"
+	"fmt"
 	"image"
 	"image/color"
 	"math"
@@ -11,15 +13,42 @@ type OverlappingModel struct {
 	N          int           // Pattern size
 	Colors     []color.Color // Colours array
 	Ground     int           // Pattern Id
+	Edges      [4][]int      // Patterns allowed on each output edge by Edge, nil for no constraint
 	Patterns   []Pattern     // Unique pattern Ids from input
 	Propagator [][][][]int   // Table of which patterns (t2) mathch a given pattern (t1) at offset (dx, dy) [t1][dx][dy][t2]
 	Fmxmn      int           // Width - n
 	Fmymn      int           // Height - n
+
+	edgeOnly [4][]int // Patterns only found on each sample edge, banned away from the output edge
 }
 
+// A side of the sample and output, see NewOverlappingModelEdges
+type Edge int
+
+const (
+	EdgeBottom Edge = iota
+	EdgeTop
+	EdgeLeft
+	EdgeRight
+)
+
 type Pattern []int
 
+// Ground pins the bottom left sample pattern to the bottom row of the
+// output and keeps it off the other rows, see NewOverlappingModelEdges
+// for using whole sample edges
 func NewOverlappingModel(img image.Image, n, width, height int, periodicInput, periodic bool, symmetry int, ground bool) *OverlappingModel {
+	return newOverlappingModel(img, n, width, height, periodicInput, periodic, symmetry, ground, nil)
+}
+
+// Same as NewOverlappingModel but restricts each of the given output
+// edges to the patterns found on the same edge of the sample, patterns
+// found nowhere else in the sample are kept off the rest of the output
+func NewOverlappingModelEdges(img image.Image, n, width, height int, periodicInput, periodic bool, symmetry int, edges ...Edge) *OverlappingModel {
+	return newOverlappingModel(img, n, width, height, periodicInput, periodic, symmetry, false, edges)
+}
+
+func newOverlappingModel(img image.Image, n, width, height int, periodicInput, periodic bool, symmetry int, ground bool, edges []Edge) *OverlappingModel {
 	m := &OverlappingModel{
 		BaseModel: &BaseModel{
 			Fmx:      width,
@@ -112,6 +141,36 @@ func NewOverlappingModel(img image.Image, n, width, height int, periodicInput, p
 
 	weights := make(map[int]int)
 	weightsKeys := make([]int, 0)
+	indices := make(map[int]int)
+
+	// Edge patterns meet the edge without wrapping unless both the sample
+	// and output wrap, in which case the edge is where they wrap
+	sampleRight, sampleBottom := dataWidth-n, dataHeight-n
+	if periodicInput && periodic {
+		sampleRight, sampleBottom = dataWidth-1, dataHeight-1
+	}
+	onEdge := func(e Edge, x, y int) bool {
+		switch e {
+		case EdgeBottom:
+			return y == sampleBottom
+		case EdgeTop:
+			return y == 0
+		case EdgeLeft:
+			return x == 0
+		case EdgeRight:
+			return x == sampleRight
+		}
+		return false
+	}
+
+	var (
+		onEdges  [4]map[int]bool // Patterns on each sample edge by index
+		offEdges [4]map[int]bool // Patterns found away from each sample edge by index
+	)
+	for _, e := range edges {
+		onEdges[e] = make(map[int]bool)
+		offEdges[e] = make(map[int]bool)
+	}
 
 	var (
 		horizontalBound int
@@ -141,19 +200,43 @@ func NewOverlappingModel(img image.Image, n, width, height int, periodicInput, p
 				if _, ok := weights[ind]; ok {
 					weights[ind]++
 				} else {
+					indices[ind] = len(weightsKeys)
 					weightsKeys = append(weightsKeys, ind)
 					weights[ind] = 1
 				}
+
 				if ground && y == verticalBound-1 && x == 0 && k == 0 {
 					// Set groung pattern
 					m.Ground = len(weightsKeys) - 1
 				}
+
+				for _, e := range edges {
+					if !onEdge(e, x, y) {
+						offEdges[e][ind] = true
+					} else if k == 0 {
+						onEdges[e][ind] = true
+					}
+				}
 			}
 		}
 	}
 
 	m.T = len(weightsKeys)
 
+	for _, e := range edges {
+		m.Edges[e] = make([]int, 0)
+		m.edgeOnly[e] = make([]int, 0)
+		for _, ind := range weightsKeys {
+			if !onEdges[e][ind] {
+				continue
+			}
+			m.Edges[e] = append(m.Edges[e], indices[ind])
+			if !offEdges[e][ind] {
+				m.edgeOnly[e] = append(m.edgeOnly[e], indices[ind])
+			}
+		}
+	}
+
 	m.Patterns = make([]Pattern, m.T)
 	m.Stationary = make([]float64, m.T)
 	m.Propagator = make([][][][]int, m.T)
@@ -163,22 +246,8 @@ func NewOverlappingModel(img image.Image, n, width, height int, periodicInput, p
 		m.Stationary[i] = float64(weights[wk])
 	}
 
-	m.Wave = make([][][]bool, m.Fmx)
-	m.Changes = make([][]bool, m.Fmx)
-
-	for x := 0; x < m.Fmx; x++ {
-		m.Wave[x] = make([][]bool, m.Fmy)
-		m.Changes[x] = make([]bool, m.Fmy)
-
-		for y := 0; y < m.Fmy; y++ {
-			m.Wave[x][y] = make([]bool, m.T)
-			m.Changes[x][y] = false
-
-			for t := 0; t < m.T; t++ {
-				m.Wave[x][y][t] = true
-			}
-		}
-	}
+	m.wave = NewWave(m.Fmx*m.Fmy, m.T)
+	m.wave.Fill()
 
 	agrees := func(p1, p2 Pattern, dx, dy int) bool {
 		var xmin, xmax, ymin, ymax int
@@ -233,96 +302,118 @@ func NewOverlappingModel(img image.Image, n, width, height int, periodicInput, p
 	m.Fmxmn = m.Fmx - m.N
 	m.Fmymn = m.Fmy - m.N
 
+	// Adjacent patterns overlap by n-1, which is enough to keep every
+	// pixel consistent once the wave is fully observed
+	adjacency := make([][][]int, len(overlapOffsets))
+	for d, offset := range overlapOffsets {
+		adjacency[d] = make([][]int, m.T)
+		for t := 0; t < m.T; t++ {
+			adjacency[d][t] = m.Propagator[t][offset.X+n-1][offset.Y+n-1]
+		}
+	}
+	m.SetTopology(Grid{m.Fmx, m.Fmy, m.Periodic, overlapOffsets[:]}, adjacency)
+
 	return m
 }
 
+// Offsets of the neighbouring patterns support is tracked for
+var overlapOffsets = [4]Offset{{1, 0}, {0, -1}, {-1, 0}, {0, 1}}
+
 func (m *OverlappingModel) OnBoundary(x, y int) bool {
 	return !m.Periodic && (x > m.Fmxmn || y > m.Fmymn)
 }
 
 func (m *OverlappingModel) Propagate() bool {
-	change := false
-	startLoop := -m.N + 1
-	endLoop := m.N
-
-	for x := 0; x < m.Fmx; x++ {
-		for y := 0; y < m.Fmy; y++ {
-			if m.Changes[x][y] {
-				m.Changes[x][y] = false
-
-				for dx := startLoop; dx < endLoop; dx++ {
-					for dy := startLoop; dy < endLoop; dy++ {
-						sx := x + dx
-						sy := y + dy
-
-						if sx < 0 {
-							sx += m.Fmx
-						} else if sx >= m.Fmx {
-							sx -= m.Fmx
-						}
-
-						if sy < 0 {
-							sy += m.Fmy
-						} else if sy >= m.Fmy {
-							sy -= m.Fmy
-						}
-
-						if !m.Periodic && (sx > m.Fmx || sy > m.Fmy) {
-							continue
-						}
-
-						allowed := m.Wave[sx][sy]
-
-						for t := 0; t < m.T; t++ {
-							if !allowed[t] {
-								continue
-							}
-
-							b := false
-							prop := m.Propagator[t][m.N-1-dx][m.N-1-dy]
-							for i := 0; i < len(prop) && !b; i++ {
-								b = m.Wave[x][y][prop[i]]
-							}
-
-							if !b {
-								m.Changes[sx][sy] = true
-								change = true
-								allowed[t] = false
-							}
-						}
-					}
-				}
-			}
-		}
-	}
-
-	return change
+	return m.PropagateBase(m)
 }
 
 func (m *OverlappingModel) Clear() {
 	m.ClearBase(m)
-	if m.Ground != -1 && m.T > 1 {
+	if m.T <= 1 {
+		return
+	}
+
+	constrained := false
+	if m.Ground != -1 {
+		constrained = true
 		for x := 0; x < m.Fmx; x++ {
 			for t := 0; t < m.T; t++ {
 				if t != m.Ground {
-					m.Wave[x][m.Fmy-1][t] = false
+					m.ban(x, m.Fmy-1, t)
 				}
 			}
 
-			m.Changes[x][m.Fmy-1] = true
-
 			for y := 0; y < m.Fmy-1; y++ {
-				m.Wave[x][y][m.Ground] = false
-				m.Changes[x][y] = true
+				m.ban(x, y, m.Ground)
 			}
 		}
+	}
+
+	for e := range m.Edges {
+		if m.Edges[e] == nil {
+			continue
+		}
+		constrained = true
 
+		allowed := NewBitset(m.T)
+		for _, t := range m.Edges[e] {
+			allowed.Add(t)
+		}
+
+		for y := 0; y < m.Fmy; y++ {
+			for x := 0; x < m.Fmx; x++ {
+				if m.onEdge(Edge(e), x, y) {
+					for t := 0; t < m.T; t++ {
+						if !allowed.Has(t) && m.Allowed(x, y, t) {
+							m.ban(x, y, t)
+						}
+					}
+				} else if !m.OnBoundary(x, y) {
+					// No pattern is placed past the last cell of a non
+					// periodic output
+					for _, t := range m.edgeOnly[e] {
+						if m.Allowed(x, y, t) {
+							m.ban(x, y, t)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	if constrained {
 		for m.Propagate() {
 			// Empty loop
 		}
 	}
 }
 
+// Puts the model back in the state of a snapshot, see Snapshot
+func (m *OverlappingModel) Restore(s *Snapshot) error {
+	return m.BaseModel.Restore(m, s)
+}
+
+// Returns whether (x, y) is the last cell a pattern can be placed in
+// towards e
+func (m *OverlappingModel) onEdge(e Edge, x, y int) bool {
+	right, bottom := m.Fmxmn, m.Fmymn
+	if m.Periodic {
+		right, bottom = m.Fmx-1, m.Fmy-1
+	}
+
+	switch e {
+	case EdgeBottom:
+		return y == bottom
+	case EdgeTop:
+		return y == 0
+	case EdgeLeft:
+		return x == 0
+	case EdgeRight:
+		return x == right
+	}
+	return false
+}
+
 func (model *OverlappingModel) RenderCompleteImage() image.Image {
 	output := make([][]color.Color, model.Fmx)
 	for i := range output {
@@ -331,11 +422,20 @@ func (model *OverlappingModel) RenderCompleteImage() image.Image {
 
 	for y := 0; y < model.Fmy; y++ {
 		for x := 0; x < model.Fmx; x++ {
-			for t := 0; t < model.T; t++ {
-				if model.Wave[x][y][t] {
-					output[x][y] = model.Colors[model.Patterns[t][0]]
-				}
+			// Cells past the last pattern of a non periodic output are
+			// drawn by the pattern overlapping them
+			cx, cy := x, y
+			if !model.Periodic && cx > model.Fmxmn {
+				cx = model.Fmxmn
+			}
+			if !model.Periodic && cy > model.Fmymn {
+				cy = model.Fmymn
 			}
+			t := model.wave.Cell(cx + cy*model.Fmx).First()
+			if t == -1 {
+				continue
+			}
+			output[x][y] = model.Colors[model.Patterns[t][x-cx+(y-cy)*model.N]]
 		}
 	}
 
@@ -377,7 +477,7 @@ func (m *OverlappingModel) RenderIncompleteImage() image.Image {
 					}
 
 					for t := 0; t < m.T; t++ {
-						if m.Wave[sx][sy][t] {
+						if m.Allowed(sx, sy, t) {
 							contributorNumber++
 							r, g, b, a := m.Colors[m.Patterns[t][dx+dy*m.N]].RGBA()
 							sR += r
@@ -422,3 +522,90 @@ func (m *OverlappingModel) Generate() (image.Image, bool) {
 	m.BaseModel.Generate(m)
 	return m.Render(), m.GenSuccess
 }
+
+func (m *OverlappingModel) IterateContext(ctx context.Context, iterations int) (image.Image, bool, bool, error) {
+	finished, err := m.BaseModel.IterateContext(ctx, m, iterations)
+	return m.Render(), finished, m.GenSuccess, err
+}
+
+func (m *OverlappingModel) GenerateContext(ctx context.Context) (image.Image, bool, error) {
+	err := m.BaseModel.GenerateContext(ctx, m)
+	return m.Render(), m.GenSuccess, err
+}
+
+// Regenerates the cells inside region, in cells, keeping every other
+// cell of state as it is, see BaseModel.State
+func (m *OverlappingModel) Inpaint(state [][]int, region image.Rectangle) (image.Image, bool) {
+	m.BaseModel.Inpaint(m, state, region)
+	return m.Render(), m.GenSuccess
+}
+
+// Regenerates the pixels of img inside rect, every pattern overlapping
+// rect is regenerated
+func (m *OverlappingModel) InpaintImage(img image.Image, rect image.Rectangle) (image.Image, bool, error) {
+	state, err := m.StateFromImage(img)
+	if err != nil {
+		return nil, false, err
+	}
+
+	region := image.Rect(rect.Min.X-m.N+1, rect.Min.Y-m.N+1, rect.Max.X, rect.Max.Y)
+
+	// Patterns wrapping around the edges overlap the far side of rect
+	if m.Periodic {
+		for x := 0; x < m.Fmx; x++ {
+			for y := 0; y < m.Fmy; y++ {
+				if region.Overlaps(image.Rect(x, y, x+m.N, y+m.N).Sub(image.Pt(m.Fmx, 0))) ||
+					region.Overlaps(image.Rect(x, y, x+m.N, y+m.N).Sub(image.Pt(0, m.Fmy))) ||
+					region.Overlaps(image.Rect(x, y, x+m.N, y+m.N).Sub(image.Pt(m.Fmx, m.Fmy))) {
+					state[x][y] = -1
+				}
+			}
+		}
+	}
+
+	out, success := m.Inpaint(state, region)
+	return out, success, nil
+}
+
+// Finds the pattern of every cell of a rendered image [x][y], cells on
+// the boundary of a non periodic output are left undecided (-1)
+func (m *OverlappingModel) StateFromImage(img image.Image) ([][]int, error) {
+	bounds := img.Bounds()
+	if bounds.Dx() != m.Fmx || bounds.Dy() != m.Fmy {
+		return nil, fmt.Errorf("wfc: image is %dx%d, want %dx%d", bounds.Dx(), bounds.Dy(), m.Fmx, m.Fmy)
+	}
+
+	matches := func(t, x, y int) bool {
+		for dy := 0; dy < m.N; dy++ {
+			for dx := 0; dx < m.N; dx++ {
+				px := bounds.Min.X + (x+dx)%m.Fmx
+				py := bounds.Min.Y + (y+dy)%m.Fmy
+				if !sameColor(img.At(px, py), m.Colors[m.Patterns[t][dx+dy*m.N]]) {
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	state := make([][]int, m.Fmx)
+	for x := 0; x < m.Fmx; x++ {
+		state[x] = make([]int, m.Fmy)
+		for y := 0; y < m.Fmy; y++ {
+			state[x][y] = -1
+			if m.OnBoundary(x, y) {
+				continue
+			}
+			for t := 0; t < m.T && state[x][y] == -1; t++ {
+				if matches(t, x, y) {
+					state[x][y] = t
+				}
+			}
+			if state[x][y] == -1 {
+				return nil, fmt.Errorf("wfc: no pattern matches the image at (%d, %d)", x, y)
+			}
+		}
+	}
+
+	return state, nil
+}
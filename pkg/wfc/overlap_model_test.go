@@ -58,3 +58,88 @@ func TestOverlappingGenerationCompletes(t *testing.T) {
 func TestOverlappingIterationIncomplete(t *testing.T) {
 	overlappingTest(t, "flowers.png", "flowers_incomplete.png", 5)
 }
+
+func TestOverlappingBacktrackingRecovers(t *testing.T) {
+	inputImg, err := utils.LoadImage("../../internal/input/flowers.png")
+	if err != nil {
+		panic(err)
+	}
+	seed := int64(6) // Runs into a contradiction without backtracking
+
+	model := NewOverlappingModel(inputImg, 3, 48, 48, true, true, 2, true)
+	model.SetSeed(seed)
+	if _, success := model.Generate(); success {
+		t.Fatal("Expected a contradiction without backtracking.")
+	}
+
+	model = NewOverlappingModel(inputImg, 3, 48, 48, true, true, 2, true)
+	model.SetSeed(seed)
+	model.SetBacktracking(0)
+	if _, success := model.Generate(); !success {
+		t.Fatal("Failed to generate image with backtracking.")
+	}
+	if model.Backtracks == 0 {
+		t.Fatal("Expected at least one backtrack.")
+	}
+}
+
+func TestOverlappingEdgesPinFloorAndCeiling(t *testing.T) {
+	inputImg, err := utils.LoadImage("../../internal/input/flowers.png")
+	if err != nil {
+		panic(err)
+	}
+
+	model := NewOverlappingModelEdges(inputImg, 3, 48, 32, false, false, 2, EdgeBottom, EdgeTop)
+	model.SetSeed(1)
+	model.SetBacktracking(0)
+	outputImg, success := model.Generate()
+	if !success {
+		t.Fatal("Failed to generate image.")
+	}
+
+	// The sample is ground at the bottom and sky at the top
+	bounds := inputImg.Bounds()
+	for x := 0; x < model.Fmx; x++ {
+		if !sameColor(outputImg.At(x, model.Fmy-1), inputImg.At(0, bounds.Max.Y-1)) {
+			t.Fatalf("Expected ground at (%d, %d).", x, model.Fmy-1)
+		}
+		if !sameColor(outputImg.At(x, 0), inputImg.At(0, 0)) {
+			t.Fatalf("Expected sky at (%d, 0).", x)
+		}
+	}
+}
+
+func TestOverlappingGroundWrapsPeriodicInput(t *testing.T) {
+	inputImg, err := utils.LoadImage("../../internal/input/flowers.png")
+	if err != nil {
+		panic(err)
+	}
+
+	model := NewOverlappingModel(inputImg, 3, 24, 24, true, false, 2, true)
+	model.SetSeed(3)
+	if _, success := model.Generate(); !success {
+		t.Fatal("Failed to generate image.")
+	}
+
+	// The ground is the pattern at the bottom left of the sample, wrapping
+	// around to its top, pinned to the last row of the output
+	bounds := inputImg.Bounds()
+	ground := model.Patterns[model.Ground]
+	for dy := 0; dy < model.N; dy++ {
+		for dx := 0; dx < model.N; dx++ {
+			want := inputImg.At(dx, (bounds.Max.Y-1+dy)%bounds.Max.Y)
+			if !sameColor(model.Colors[ground[dx+dy*model.N]], want) {
+				t.Fatalf("Expected the ground pattern to wrap around the sample at (%d, %d).", dx, dy)
+			}
+		}
+	}
+
+	state := model.State()
+	for x := 0; x < model.Fmx; x++ {
+		for y := 0; y < model.Fmy; y++ {
+			if (state[x][y] == model.Ground) != (y == model.Fmy-1) {
+				t.Fatalf("Expected the ground pattern only on the last row, got %d at (%d, %d).", state[x][y], x, y)
+			}
+		}
+	}
+}
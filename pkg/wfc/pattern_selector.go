@@ -0,0 +1,102 @@
+package wfc
+
+// Chooses which of the patterns remaining at a cell it is observed as
+type PatternSelector interface {
+	SelectPattern(b *BaseModel, x, y int) int
+}
+
+// Random pattern weighted by how often it appears in the input, the default
+type WeightedRandom struct{}
+
+func (WeightedRandom) SelectPattern(b *BaseModel, x, y int) int {
+	return b.weightedPattern(x, y, func(t int) float64 {
+		return b.Stationary[t]
+	})
+}
+
+// Random pattern weighted by how often it appears in the input divided
+// by how many cells it already fills, evening out the patterns used
+type UsageWeighted struct {
+	Group []int // Group of each pattern (t) sharing a count, nil to count patterns alone
+}
+
+func (u UsageWeighted) SelectPattern(b *BaseModel, x, y int) int {
+	return b.weightedPattern(x, y, func(t int) float64 {
+		return b.Stationary[t] / float64(1+u.used(b, t))
+	})
+}
+
+func (u UsageWeighted) used(b *BaseModel, t int) int {
+	if u.Group == nil {
+		return b.Used(t)
+	}
+
+	n := 0
+	for t2 := 0; t2 < b.T; t2++ {
+		if u.Group[t2] == u.Group[t] {
+			n += b.Used(t2)
+		}
+	}
+	return n
+}
+
+// Pattern filling the fewest cells, ties are weighted random
+type LeastUsed struct{}
+
+func (LeastUsed) SelectPattern(b *BaseModel, x, y int) int {
+	least := -1
+	for t := 0; t < b.T; t++ {
+		if b.Allowed(x, y, t) && (least == -1 || b.Used(t) < least) {
+			least = b.Used(t)
+		}
+	}
+
+	return b.weightedPattern(x, y, func(t int) float64 {
+		if b.Used(t) == least {
+			return b.Stationary[t]
+		}
+		return 0.0
+	})
+}
+
+// Lowest remaining pattern, without using the random number generator
+type LowestIndex struct{}
+
+func (LowestIndex) SelectPattern(b *BaseModel, x, y int) int {
+	return b.wave.Cell(x + y*b.Fmx).First()
+}
+
+// Sets the strategy choosing the pattern of an observed cell
+func (b *BaseModel) SetPatternSelector(selector PatternSelector) {
+	b.PatternSelector = selector
+}
+
+// Returns how many cells are decided as pattern t
+func (b *BaseModel) Used(t int) int {
+	return b.used[t]
+}
+
+// Picks a random pattern remaining at (x, y) using the given weights
+func (b *BaseModel) weightedPattern(x, y int, weight func(t int) float64) int {
+	if len(b.distribution) != b.T {
+		b.distribution = make([]float64, b.T)
+	}
+	distribution := b.distribution
+
+	for t := 0; t < b.T; t++ {
+		if b.Allowed(x, y, t) {
+			distribution[t] = weight(t)
+		} else {
+			distribution[t] = 0.0
+		}
+	}
+
+	return randomIndice(distribution, b.Rng())
+}
+
+func (b *BaseModel) selectPattern(x, y int) int {
+	if b.PatternSelector == nil {
+		return WeightedRandom{}.SelectPattern(b, x, y)
+	}
+	return b.PatternSelector.SelectPattern(b, x, y)
+}
@@ -0,0 +1,50 @@
+package wfc
+
+import (
+	"testing"
+
+	"wfc/pkg/utils"
+)
+
+func TestLowestIndexIsDeterministic(t *testing.T) {
+	data := MakeTiledData("../../internal/input/", "castle_data.json")
+
+	generate := func(seed int64) *TiledModel {
+		model := NewTiledModel(data, 10, 10, false)
+		model.SetSeed(seed)
+		model.SetBacktracking(0)
+		model.SetCellSelector(Scanline{})
+		model.SetPatternSelector(LowestIndex{})
+		return model
+	}
+
+	first, success := generate(1).Generate()
+	if !success {
+		t.Fatal("Failed to generate image.")
+	}
+	second, _ := generate(2).Generate()
+
+	if !utils.CompareImages(first, second) {
+		t.Fatal("Expected the same image regardless of seed.")
+	}
+}
+
+func TestUsageWeightedCountsTiles(t *testing.T) {
+	data := MakeTiledData("../../internal/input/", "castle_data.json")
+	model := NewTiledModel(data, 20, 20, false)
+	model.SetSeed(1)
+	model.SetBacktracking(0)
+	model.SetPatternSelector(UsageWeighted{Group: model.TileOf})
+
+	if _, success := model.Generate(); !success {
+		t.Fatal("Failed to generate image.")
+	}
+
+	used := 0
+	for p := 0; p < model.T; p++ {
+		used += model.Used(p)
+	}
+	if used != 20*20 {
+		t.Fatalf("Expected every cell to be counted once, got %d.", used)
+	}
+}
@@ -0,0 +1,147 @@
+package wfc
+
+// Position of a neighbour relative to a cell
+type Offset struct {
+	X int
+	Y int
+}
+
+// A pattern (t) removed from (x, y) whose neighbours still need updating
+type banned struct {
+	x int
+	y int
+	t int
+}
+
+// Sets up worklist propagation, adjacency[d][t1] lists the patterns
+// allowed in direction d of topology from a cell holding pattern t1
+func (b *BaseModel) SetTopology(topology Topology, adjacency [][][]int) {
+	b.Topology = topology
+	b.Adjacency = adjacency
+	directions := topology.Directions()
+
+	// Every pattern starts with the support of all its possible neighbours
+	b.initialSupport = make([]int32, b.T*directions)
+	for d := 0; d < directions; d++ {
+		for t1 := 0; t1 < b.T; t1++ {
+			for _, t2 := range adjacency[d][t1] {
+				b.initialSupport[t2*directions+d]++
+			}
+		}
+	}
+
+	b.compatible = make([]int32, b.Fmx*b.Fmy*len(b.initialSupport))
+}
+
+// Resets the support counts for an empty wave
+func (b *BaseModel) clearSupport() {
+	for i := 0; i < len(b.compatible); i += len(b.initialSupport) {
+		copy(b.compatible[i:], b.initialSupport)
+	}
+	b.stack = b.stack[:0]
+}
+
+// Returns the support count of pattern t at (x, y) from direction d
+func (b *BaseModel) support(x, y, t, d int) *int32 {
+	return &b.compatible[((x+y*b.Fmx)*b.T+t)*len(b.Adjacency)+d]
+}
+
+// Removes pattern t from (x, y), queueing its neighbours for propagation
+func (b *BaseModel) ban(x, y, t int) {
+	i := x + y*b.Fmx
+	if !b.wave.Get(i, t) {
+		return
+	}
+
+	b.wave.Unset(i, t)
+	if b.Observer != nil {
+		b.Observer.PatternBanned(x, y, t)
+	}
+	b.removeWeight(i, t)
+	b.stack = append(b.stack, banned{x, y, t})
+
+	if b.Backtracking {
+		b.trail = append(b.trail, banned{x, y, t})
+	}
+}
+
+// Reverses a propagated ban, restoring the pattern and the support it
+// gave its neighbours
+func (b *BaseModel) unban(sm Checker, e banned) {
+	i := e.x + e.y*b.Fmx
+	b.wave.Set(i, e.t)
+	b.restoreWeight(sm, i, e.t)
+	if o, ok := b.Observer.(BacktrackObserver); ok {
+		o.PatternRestored(e.x, e.y, e.t)
+	}
+
+	for d := range b.Adjacency {
+		x2, y2, ok := b.neighbour(sm, e.x, e.y, d)
+		if !ok {
+			continue
+		}
+
+		for _, t2 := range b.Adjacency[d][e.t] {
+			*b.support(x2, y2, t2, d)++
+		}
+	}
+}
+
+// Returns the cell in direction d from (x, y), ok is false if it is off
+// the edge of the topology or on the boundary
+func (b *BaseModel) neighbour(sm Checker, x, y, d int) (int, int, bool) {
+	x2, y2, ok := b.Topology.Neighbour(x, y, d)
+	return x2, y2, ok && !sm.OnBoundary(x2, y2)
+}
+
+// Removes support for the patterns neighbouring every banned pattern
+// until nothing more can be banned or a cell runs out of patterns,
+// returns whether anything was banned
+func (b *BaseModel) PropagateBase(sm Solver) bool {
+	change := false
+
+	for len(b.stack) > 0 {
+		e := b.stack[len(b.stack)-1]
+		b.stack = b.stack[:len(b.stack)-1]
+		change = true
+
+		if b.contradiction {
+			// No point spreading a contradiction over the whole wave, the
+			// support is still removed so the ban can be undone exactly
+			b.removeSupport(sm, e)
+			continue
+		}
+
+		for d := range b.Adjacency {
+			x2, y2, ok := b.neighbour(sm, e.x, e.y, d)
+			if !ok {
+				continue
+			}
+
+			for _, t2 := range b.Adjacency[d][e.t] {
+				s := b.support(x2, y2, t2, d)
+				*s--
+				if *s == 0 {
+					b.ban(x2, y2, t2)
+				}
+			}
+		}
+	}
+
+	return change
+}
+
+// Removes the support a banned pattern gave its neighbours without
+// banning anything
+func (b *BaseModel) removeSupport(sm Checker, e banned) {
+	for d := range b.Adjacency {
+		x2, y2, ok := b.neighbour(sm, e.x, e.y, d)
+		if !ok {
+			continue
+		}
+
+		for _, t2 := range b.Adjacency[d][e.t] {
+			*b.support(x2, y2, t2, d)--
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package wfc
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"os"
+)
+
+// Frames a Recorder keeps by default before thinning them out
+const DefaultMaxFrames = 256
+
+// Observer capturing a frame of a generation every few observations,
+// set it with SetObserver then write the frames once generation is over.
+// Frames are kept with 256 colours at most, or written to disk as they
+// are captured when Pattern is set
+type Recorder struct {
+	Every     int                // Observations between frames, doubled whenever MaxFrames is reached
+	Delay     int                // Time each gif frame is shown, in 100ths of a second
+	Render    func() image.Image // Draws a frame, usually the model's RenderIncompleteImage
+	MaxFrames int                // Frames kept before every other one is dropped, 0 for no limit
+	Pattern   string             // Printf style png file name given the frame number, e.g. "frames/%04d.png", empty to keep frames in memory
+	Frames    []*image.Paletted  // Frames kept so far, the last is the finished output
+	count     int                // Observations so far
+	written   int                // Frames written to Pattern so far
+	err       error              // First error writing to Pattern
+	palette   color.Palette      // Exact colours of the frames so far, up to 256
+	index     map[color.RGBA]int // Position of each colour in palette
+	buf       *image.RGBA        // Frame being quantised
+}
+
+// Records a frame of render every every observations, shown for 5/100s,
+// keeping up to DefaultMaxFrames frames
+func NewRecorder(render func() image.Image, every int) *Recorder {
+	if every < 1 {
+		every = 1
+	}
+	return &Recorder{Every: every, Delay: 5, Render: render, MaxFrames: DefaultMaxFrames}
+}
+
+// Frames are taken before the next observation, once the previous one
+// has been propagated
+func (r *Recorder) CellObserved(x, y, t int) {
+	if r.count%r.Every == 0 {
+		r.capture()
+	}
+	r.count++
+}
+
+func (r *Recorder) PatternBanned(x, y, t int) {}
+
+func (r *Recorder) Contradiction(x, y int) {}
+
+func (r *Recorder) Finished(success bool) {
+	r.capture()
+}
+
+// The first error writing a frame to Pattern
+func (r *Recorder) Err() error {
+	return r.err
+}
+
+func (r *Recorder) capture() {
+	if r.Pattern != "" {
+		if r.err == nil {
+			r.err = writePNG(fmt.Sprintf(r.Pattern, r.written), r.Render())
+			r.written++
+		}
+		return
+	}
+
+	if r.MaxFrames > 0 && len(r.Frames) >= r.MaxFrames {
+		r.thin()
+	}
+	r.Frames = append(r.Frames, r.quantise(r.Render()))
+}
+
+// Drops every other frame and halves the frame rate from now on
+func (r *Recorder) thin() {
+	kept := r.Frames[:0]
+	for i, frame := range r.Frames {
+		if i%2 == 0 {
+			kept = append(kept, frame)
+		}
+	}
+	for i := len(kept); i < len(r.Frames); i++ {
+		r.Frames[i] = nil
+	}
+	r.Frames = kept
+	r.Every *= 2
+}
+
+// Converts a frame to the colours of the frames so far, adding its own
+// while there are no more than 256, otherwise it is dithered to the
+// Plan 9 palette
+func (r *Recorder) quantise(img image.Image) *image.Paletted {
+	bounds := img.Bounds()
+	if r.buf == nil || r.buf.Bounds() != bounds {
+		r.buf = image.NewRGBA(bounds)
+	}
+	draw.Draw(r.buf, bounds, img, bounds.Min, draw.Src)
+	if r.index == nil {
+		r.index = make(map[color.RGBA]int)
+	}
+
+	frame := image.NewPaletted(bounds, nil)
+	for i, j := 0, 0; i < len(r.buf.Pix); i, j = i+4, j+1 {
+		c := color.RGBA{r.buf.Pix[i], r.buf.Pix[i+1], r.buf.Pix[i+2], r.buf.Pix[i+3]}
+		k, ok := r.index[c]
+		if !ok {
+			if len(r.palette) == 256 {
+				frame.Palette = palette.Plan9
+				draw.FloydSteinberg.Draw(frame, bounds, r.buf, bounds.Min)
+				return frame
+			}
+			k = len(r.palette)
+			r.index[c] = k
+			r.palette = append(r.palette, c)
+		}
+		frame.Pix[j] = uint8(k)
+	}
+
+	// Later frames only append to the palette
+	frame.Palette = r.palette[:len(r.palette):len(r.palette)]
+	return frame
+}
+
+// Writes the frames as a looping animated gif
+func (r *Recorder) WriteGIF(w io.Writer) error {
+	if len(r.Frames) == 0 {
+		return fmt.Errorf("wfc: no frames recorded")
+	}
+
+	anim := &gif.GIF{Image: r.Frames}
+	for range r.Frames {
+		anim.Delay = append(anim.Delay, r.Delay)
+	}
+	return gif.EncodeAll(w, anim)
+}
+
+// Writes every kept frame as a png named by the printf style pattern,
+// given the frame number from 0, e.g. "frames/%04d.png"
+func (r *Recorder) WritePNGs(pattern string) error {
+	for i, frame := range r.Frames {
+		if err := writePNG(fmt.Sprintf(pattern, i), frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writePNG(name string, img image.Image) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	err = png.Encode(f, img)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,97 @@
+package wfc
+
+import (
+	"bytes"
+	"fmt"
+	"image/gif"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecorderCapturesFrames(t *testing.T) {
+	data := MakeTiledData("../../internal/input/", "castle_data.json")
+	model := NewTiledModel(data, 10, 10, false)
+	model.SetSeed(1)
+
+	recorder := NewRecorder(model.RenderIncompleteImage, 10)
+	model.SetObserver(recorder)
+	if _, success := model.Generate(); !success {
+		t.Fatal("Failed to generate image on the first try.")
+	}
+
+	// One frame per 10 observations and the finished output
+	if want := (recorder.count+9)/10 + 1; len(recorder.Frames) != want {
+		t.Fatalf("Recorded %d frames for %d observations, want %d.", len(recorder.Frames), recorder.count, want)
+	}
+
+	final := recorder.Frames[len(recorder.Frames)-1]
+	complete := model.RenderCompleteImage()
+	bounds := complete.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if !sameColor(final.At(x, y), complete.At(x, y)) {
+				t.Fatalf("Last frame differs from the output at (%d, %d).", x, y)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := recorder.WriteGIF(&buf); err != nil {
+		t.Fatal(err)
+	}
+	anim, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anim.Image) != len(recorder.Frames) {
+		t.Fatalf("Gif has %d frames, want %d.", len(anim.Image), len(recorder.Frames))
+	}
+}
+
+func TestRecorderThinsFrames(t *testing.T) {
+	data := MakeTiledData("../../internal/input/", "castle_data.json")
+	model := NewTiledModel(data, 10, 10, false)
+	model.SetSeed(1)
+
+	recorder := NewRecorder(model.RenderIncompleteImage, 1)
+	recorder.MaxFrames = 8
+	model.SetObserver(recorder)
+	if _, success := model.Generate(); !success {
+		t.Fatal("Failed to generate image on the first try.")
+	}
+
+	if len(recorder.Frames) > recorder.MaxFrames+1 {
+		t.Fatalf("Kept %d frames, want at most %d.", len(recorder.Frames), recorder.MaxFrames+1)
+	}
+	if recorder.Every == 1 {
+		t.Fatal("Expected the frame rate to be halved.")
+	}
+}
+
+func TestRecorderWritesPNGsAsCaptured(t *testing.T) {
+	data := MakeTiledData("../../internal/input/", "castle_data.json")
+	model := NewTiledModel(data, 10, 10, false)
+	model.SetSeed(1)
+
+	dir := t.TempDir()
+	recorder := NewRecorder(model.RenderIncompleteImage, 10)
+	recorder.Pattern = filepath.Join(dir, "%03d.png")
+	model.SetObserver(recorder)
+	if _, success := model.Generate(); !success {
+		t.Fatal("Failed to generate image on the first try.")
+	}
+	if err := recorder.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(recorder.Frames) != 0 {
+		t.Fatalf("Kept %d frames in memory, want none.", len(recorder.Frames))
+	}
+	want := (recorder.count+9)/10 + 1
+	for i := 0; i < want; i++ {
+		if _, err := os.Stat(fmt.Sprintf(recorder.Pattern, i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
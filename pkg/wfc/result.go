@@ -0,0 +1,136 @@
+package wfc
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"io"
+	"strconv"
+)
+
+// A decided cell of a tiled output
+type TileCell struct {
+	Tile    string `json:"tile"`    // Name of the data tile
+	Variant int    `json:"variant"` // Variant of the tile, see TiledModel.Variant
+}
+
+// The tiles chosen by a TiledModel
+type TiledResult struct {
+	Width  int           `json:"width"`
+	Height int           `json:"height"`
+	Cells  [][]*TileCell `json:"cells"` // [x][y], nil if undecided
+}
+
+// Returns the tile of every cell
+func (m *TiledModel) Result() TiledResult {
+	r := TiledResult{
+		Width:  m.Fmx,
+		Height: m.Fmy,
+		Cells:  make([][]*TileCell, m.Fmx),
+	}
+
+	for x, column := range m.State() {
+		r.Cells[x] = make([]*TileCell, m.Fmy)
+		for y, t := range column {
+			if t != -1 {
+				r.Cells[x][y] = &TileCell{Tile: m.TileNames[m.TileOf[t]], Variant: m.Variant[t]}
+			}
+		}
+	}
+	return r
+}
+
+func (r TiledResult) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	return enc.Encode(r)
+}
+
+// Writes one x,y,tile,variant record per cell under a header, row by
+// row, undecided cells have an empty tile and variant
+func (r TiledResult) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"x", "y", "tile", "variant"})
+	for y := 0; y < r.Height; y++ {
+		for x := 0; x < r.Width; x++ {
+			record := []string{strconv.Itoa(x), strconv.Itoa(y), "", ""}
+			if c := r.Cells[x][y]; c != nil {
+				record[2], record[3] = c.Tile, strconv.Itoa(c.Variant)
+			}
+			cw.Write(record)
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// The patterns and colours chosen by an OverlappingModel
+type OverlapResult struct {
+	Width    int      `json:"width"`
+	Height   int      `json:"height"`
+	Palette  []string `json:"palette"`  // Colors as #rrggbb, or #rrggbbaa if not opaque
+	Patterns [][]int  `json:"patterns"` // Pattern decided at each cell [x][y], -1 if undecided or past the last pattern of a non periodic output
+	Colors   [][]int  `json:"colors"`   // Index into Palette of each pixel [x][y], -1 if undecided
+}
+
+// Returns the pattern and colour of every cell
+func (m *OverlappingModel) Result() OverlapResult {
+	r := OverlapResult{
+		Width:    m.Fmx,
+		Height:   m.Fmy,
+		Patterns: m.State(),
+		Colors:   make([][]int, m.Fmx),
+	}
+
+	for _, c := range m.Colors {
+		r.Palette = append(r.Palette, hexColor(c))
+	}
+
+	for x := 0; x < m.Fmx; x++ {
+		r.Colors[x] = make([]int, m.Fmy)
+		for y := 0; y < m.Fmy; y++ {
+			// Same as RenderCompleteImage
+			cx, cy := x, y
+			if !m.Periodic && cx > m.Fmxmn {
+				cx = m.Fmxmn
+			}
+			if !m.Periodic && cy > m.Fmymn {
+				cy = m.Fmymn
+			}
+
+			r.Colors[x][y] = -1
+			if t := r.Patterns[cx][cy]; t != -1 {
+				r.Colors[x][y] = m.Patterns[t][x-cx+(y-cy)*m.N]
+			}
+		}
+	}
+	return r
+}
+
+func (r OverlapResult) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	return enc.Encode(r)
+}
+
+// Writes one x,y,pattern,color record per cell under a header, row by
+// row, the colour is an index into Palette
+func (r OverlapResult) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"x", "y", "pattern", "color"})
+	for y := 0; y < r.Height; y++ {
+		for x := 0; x < r.Width; x++ {
+			cw.Write([]string{strconv.Itoa(x), strconv.Itoa(y), strconv.Itoa(r.Patterns[x][y]), strconv.Itoa(r.Colors[x][y])})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Formats c as #rrggbb, or #rrggbbaa if it is not opaque
+func hexColor(c color.Color) string {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	if n.A == 255 {
+		return fmt.Sprintf("#%02x%02x%02x", n.R, n.G, n.B)
+	}
+	return fmt.Sprintf("#%02x%02x%02x%02x", n.R, n.G, n.B, n.A)
+}
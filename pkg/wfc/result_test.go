@@ -0,0 +1,81 @@
+package wfc
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+	"wfc/pkg/utils"
+)
+
+func TestTiledResultNamesTiles(t *testing.T) {
+	data := MakeTiledData("../../internal/input/", "castle_data.json")
+	model := NewTiledModel(data, 12, 8, false)
+	model.SetSeed(1)
+	if _, success := model.Generate(); !success {
+		t.Fatal("Failed to generate image on the first try.")
+	}
+
+	result := model.Result()
+	for x, column := range model.State() {
+		for y, p := range column {
+			c := result.Cells[x][y]
+			if c == nil {
+				t.Fatalf("Missing cell at (%d, %d).", x, y)
+			}
+			want, err := model.TilePatterns(c.Tile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want[c.Variant] != p {
+				t.Fatalf("Cell (%d, %d) is %s variant %d, want pattern %d.", x, y, c.Tile, c.Variant, p)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := result.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1+12*8 {
+		t.Fatalf("Expected a header and %d records, got %d lines.", 12*8, len(records))
+	}
+	if r := records[2]; r[0] != "1" || r[1] != "0" || r[2] != result.Cells[1][0].Tile {
+		t.Fatalf("Expected the second record to be cell (1, 0), got %v.", r)
+	}
+}
+
+func TestOverlappingResultMatchesImage(t *testing.T) {
+	inputImg, err := utils.LoadImage("../../internal/input/flowers.png")
+	if err != nil {
+		panic(err)
+	}
+	model := NewOverlappingModel(inputImg, 3, 24, 24, true, false, 2, true)
+	model.SetSeed(3)
+	img, success := model.Generate()
+	if !success {
+		t.Fatal("Failed to generate image on the first try.")
+	}
+
+	var buf bytes.Buffer
+	if err := model.Result().WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var result OverlapResult
+	if err := json.Unmarshal(buf.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+
+	for x := 0; x < result.Width; x++ {
+		for y := 0; y < result.Height; y++ {
+			i := result.Colors[x][y]
+			if i == -1 || !sameColor(model.Colors[i], img.At(x, y)) {
+				t.Fatalf("Colour %d at (%d, %d) does not match the image.", i, x, y)
+			}
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package wfc
+
+import "math"
+
+// Chooses which undecided cell is observed next, the cell with the
+// lowest priority wins. Priorities are recomputed whenever a cell loses
+// or regains a pattern, so they may only depend on the state of the cell
+type CellSelector interface {
+	Priority(b *BaseModel, x, y int) float64
+}
+
+// Cell with the lowest Shannon entropy first, the default
+type MinEntropy struct{}
+
+func (MinEntropy) Priority(b *BaseModel, x, y int) float64 {
+	return b.Entropy(x, y) + b.Noise(x, y)
+}
+
+// Cell with the fewest remaining patterns first
+type MinRemainingValues struct{}
+
+func (MinRemainingValues) Priority(b *BaseModel, x, y int) float64 {
+	return float64(b.sumsOfOnes[x+y*b.Fmx]) + b.Noise(x, y)
+}
+
+// Cells left to right, top to bottom
+type Scanline struct{}
+
+func (Scanline) Priority(b *BaseModel, x, y int) float64 {
+	return float64(x + y*b.Fmx)
+}
+
+// Cells in a random order, redrawn on every Clear
+type RandomCell struct{}
+
+func (RandomCell) Priority(b *BaseModel, x, y int) float64 {
+	return b.Noise(x, y)
+}
+
+// Cells in rings around the centre, walking each ring by angle
+type Spiral struct{}
+
+func (Spiral) Priority(b *BaseModel, x, y int) float64 {
+	dx := float64(x) - float64(b.Fmx-1)/2
+	dy := float64(y) - float64(b.Fmy-1)/2
+	ring := math.Max(math.Abs(dx), math.Abs(dy))
+	angle := (math.Atan2(dy, dx) + math.Pi) / (2 * math.Pi)
+	return math.Ceil(ring) + angle*0.999
+}
+
+// Sets the heuristic choosing the next cell to observe, takes effect
+// on the next Clear
+func (b *BaseModel) SetCellSelector(selector CellSelector) {
+	b.CellSelector = selector
+}
+
+// Returns the Shannon entropy of the patterns remaining at (x, y)
+func (b *BaseModel) Entropy(x, y int) float64 {
+	return b.entropies[x+y*b.Fmx]
+}
+
+// Returns the random tie breaker of (x, y), in [0, 0.000001) and
+// redrawn on every Clear
+func (b *BaseModel) Noise(x, y int) float64 {
+	return b.noise[x+y*b.Fmx]
+}
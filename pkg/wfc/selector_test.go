@@ -0,0 +1,49 @@
+package wfc
+
+import "testing"
+
+func firstObserved(t *testing.T, selector CellSelector) (int, int) {
+	data := MakeTiledData("../../internal/input/", "castle_data.json")
+	model := NewTiledModel(data, 9, 9, false)
+	model.SetSeed(42)
+	model.SetCellSelector(selector)
+	model.Clear()
+
+	x, y, ok := model.nextCell()
+	if !ok {
+		t.Fatal("Expected an undecided cell.")
+	}
+
+	model.Iterate(1)
+	if model.Remaining(x, y) != 1 {
+		t.Fatalf("Expected (%d, %d) to be observed.", x, y)
+	}
+	return x, y
+}
+
+func TestScanlineSelectsTopLeftFirst(t *testing.T) {
+	if x, y := firstObserved(t, Scanline{}); x != 0 || y != 0 {
+		t.Fatalf("Expected (0, 0), got (%d, %d).", x, y)
+	}
+}
+
+func TestSpiralSelectsCentreFirst(t *testing.T) {
+	if x, y := firstObserved(t, Spiral{}); x != 4 || y != 4 {
+		t.Fatalf("Expected (4, 4), got (%d, %d).", x, y)
+	}
+}
+
+func TestSelectorsGenerate(t *testing.T) {
+	data := MakeTiledData("../../internal/input/", "castle_data.json")
+
+	for _, selector := range []CellSelector{MinEntropy{}, MinRemainingValues{}, Scanline{}, Spiral{}} {
+		model := NewTiledModel(data, 20, 20, false)
+		model.SetSeed(1)
+		model.SetBacktracking(0)
+		model.SetCellSelector(selector)
+
+		if _, success := model.Generate(); !success {
+			t.Errorf("%T: failed to generate image.", selector)
+		}
+	}
+}
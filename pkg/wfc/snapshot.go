@@ -0,0 +1,204 @@
+package wfc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// Version of the snapshot format written by WriteJSON
+const SnapshotVersion = 1
+
+// The state of a generation, enough to resume it in another process
+// with a model built from the same data. Support counts and entropies
+// are rebuilt from the wave on Restore
+type Snapshot struct {
+	Version          int                  `json:"version"`
+	Width            int                  `json:"width"`            // Fmx of the model
+	Height           int                  `json:"height"`           // Fmy of the model
+	Patterns         int                  `json:"patterns"`         // T of the model
+	Initialized      bool                 `json:"initialized"`      // False before the first Clear, nothing else but the constraints is set
+	GenSuccess       bool                 `json:"genSuccess"`       //
+	Seeded           bool                 `json:"seeded"`           // Seed and Draws are set, false if Rng was set by hand
+	Seed             int64                `json:"seed"`             //
+	Draws            int64                `json:"draws"`            // Numbers taken from the generator since seeding
+	Wave             []uint64             `json:"wave"`             // Bits of every cell, see Wave
+	Noise            []float64            `json:"noise"`            // Tie breaker of every cell
+	Weights          []float64            `json:"weights"`          // Weight of the patterns remaining in each cell
+	WeightLogWeights []float64            `json:"weightLogWeights"` // Sum of weight * log(weight) of the patterns remaining in each cell
+	Backtracks       int                  `json:"backtracks"`       //
+	History          [][4]int             `json:"history"`          // x, y, t and trail length of each observation that can be undone
+	Trail            [][3]int             `json:"trail"`            // x, y, t of every ban since clearing
+	Pending          [][3]int             `json:"pending"`          // x, y, t of bans not propagated yet
+	Constraints      []SnapshotConstraint `json:"constraints"`      //
+}
+
+type SnapshotConstraint struct {
+	X       int      `json:"x"`
+	Y       int      `json:"y"`
+	Allowed []uint64 `json:"allowed"` // Bits of the allowed patterns, see Bitset
+}
+
+// Records the current state, Rng is only saved if it was seeded by
+// SetSeed or Clear
+func (b *BaseModel) Snapshot() *Snapshot {
+	s := &Snapshot{
+		Version:     SnapshotVersion,
+		Width:       b.Fmx,
+		Height:      b.Fmy,
+		Patterns:    b.T,
+		Initialized: b.InitField,
+		GenSuccess:  b.GenSuccess,
+		Backtracks:  b.Backtracks,
+		History:     make([][4]int, 0, len(b.history)),
+		Trail:       make([][3]int, 0, len(b.trail)),
+		Pending:     make([][3]int, 0, len(b.stack)),
+		Constraints: make([]SnapshotConstraint, 0, len(b.constraints)),
+	}
+
+	if b.rng != nil {
+		s.Seeded = true
+		s.Seed = b.rng.seed
+		s.Draws = b.rng.draws
+	}
+
+	for _, c := range b.constraints {
+		s.Constraints = append(s.Constraints, SnapshotConstraint{c.x, c.y, append([]uint64(nil), c.allowed...)})
+	}
+
+	if !b.InitField {
+		return s
+	}
+
+	s.Wave = append([]uint64(nil), b.wave.words...)
+	s.Noise = append([]float64(nil), b.noise...)
+	s.Weights = append([]float64(nil), b.sumsOfWeights...)
+	s.WeightLogWeights = append([]float64(nil), b.sumsOfWeightLogWeights...)
+	for _, d := range b.history {
+		s.History = append(s.History, [4]int{d.x, d.y, d.t, d.trail})
+	}
+	for _, e := range b.trail {
+		s.Trail = append(s.Trail, [3]int{e.x, e.y, e.t})
+	}
+	for _, e := range b.stack {
+		s.Pending = append(s.Pending, [3]int{e.x, e.y, e.t})
+	}
+
+	return s
+}
+
+// Puts the model back in the state of a snapshot taken from a model
+// built with the same data and size. A snapshot taken with an Rng set by
+// hand keeps the Rng of the model, or a time seeded one if it has none
+func (b *BaseModel) Restore(sm Solver, s *Snapshot) error {
+	if s.Version != SnapshotVersion {
+		return fmt.Errorf("wfc: snapshot version %d, want %d", s.Version, SnapshotVersion)
+	}
+	if s.Width != b.Fmx || s.Height != b.Fmy || s.Patterns != b.T {
+		return fmt.Errorf("wfc: snapshot of a %dx%d output with %d patterns, model is %dx%d with %d", s.Width, s.Height, s.Patterns, b.Fmx, b.Fmy, b.T)
+	}
+
+	cells := b.Fmx * b.Fmy
+	if s.Initialized && (len(s.Wave) != len(b.wave.words) || len(s.Noise) != cells || len(s.Weights) != cells || len(s.WeightLogWeights) != cells) {
+		return fmt.Errorf("wfc: snapshot cell data does not match a %dx%d output", b.Fmx, b.Fmy)
+	}
+
+	constraints := make([]constraint, 0, len(s.Constraints))
+	for _, c := range s.Constraints {
+		if err := b.checkCell(c.X, c.Y); err != nil {
+			return err
+		}
+		if len(c.Allowed) != len(NewBitset(b.T)) {
+			return fmt.Errorf("wfc: snapshot constraint at (%d, %d) has %d words, want %d", c.X, c.Y, len(c.Allowed), len(NewBitset(b.T)))
+		}
+		constraints = append(constraints, constraint{c.X, c.Y, append(Bitset(nil), c.Allowed...)})
+	}
+	b.constraints = constraints
+
+	if s.Seeded {
+		b.seedRng(s.Seed, s.Draws)
+	} else if b.Rng == nil {
+		// Taken with an Rng set by hand, which cannot be restored
+		b.seedRng(time.Now().UnixNano(), 0)
+	}
+
+	b.InitField = s.Initialized
+	b.GenSuccess = s.GenSuccess
+	b.Backtracks = s.Backtracks
+	if !s.Initialized {
+		return nil
+	}
+
+	copy(b.wave.words, s.Wave)
+	if len(b.sumsOfOnes) != cells || len(b.weightLogWeights) != b.T {
+		b.initEntropy()
+	}
+	copy(b.noise, s.Noise)
+	copy(b.sumsOfWeights, s.Weights)
+	copy(b.sumsOfWeightLogWeights, s.WeightLogWeights)
+
+	b.history = b.history[:0]
+	for _, d := range s.History {
+		b.history = append(b.history, decision{d[0], d[1], d[2], d[3]})
+	}
+	b.trail = b.trail[:0]
+	for _, e := range s.Trail {
+		b.trail = append(b.trail, banned{e[0], e[1], e[2]})
+	}
+
+	// Every ban already propagated has taken its support from its
+	// neighbours, pending bans still hold theirs
+	b.clearSupport()
+	pending := make(map[banned]bool)
+	for _, e := range s.Pending {
+		b.stack = append(b.stack, banned{e[0], e[1], e[2]})
+		pending[banned{e[0], e[1], e[2]}] = true
+	}
+
+	b.contradiction = false
+	for t := range b.used {
+		b.used[t] = 0
+	}
+	for i := 0; i < cells; i++ {
+		x, y := i%b.Fmx, i/b.Fmx
+		b.sumsOfOnes[i] = b.wave.Count(i)
+		sum := b.sumsOfWeights[i]
+		b.entropies[i] = math.Log(sum) - b.sumsOfWeightLogWeights[i]/sum
+
+		switch b.sumsOfOnes[i] {
+		case 0:
+			b.contradiction = true
+		case 1:
+			b.used[b.wave.Cell(i).First()]++
+		}
+
+		for t := 0; t < b.T; t++ {
+			if e := (banned{x, y, t}); !b.wave.Get(i, t) && !pending[e] {
+				b.removeSupport(sm, e)
+			}
+		}
+	}
+
+	b.rebuildFrontier(sm)
+	return nil
+}
+
+// Writes the snapshot as json
+func (s *Snapshot) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	return enc.Encode(s)
+}
+
+// Reads a snapshot written by WriteJSON, checking its version
+func ReadSnapshot(r io.Reader) (*Snapshot, error) {
+	var s Snapshot
+	if err := json.NewDecoder(r).Decode(&s); err != nil {
+		return nil, fmt.Errorf("wfc: reading snapshot: %w", err)
+	}
+	if s.Version != SnapshotVersion {
+		return nil, fmt.Errorf("wfc: snapshot version %d, want %d", s.Version, SnapshotVersion)
+	}
+	return &s, nil
+}
@@ -0,0 +1,115 @@
+package wfc
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"wfc/pkg/utils"
+)
+
+// Round trips the snapshot through json as another process would
+func reloadSnapshot(t *testing.T, s *Snapshot) *Snapshot {
+	var buf bytes.Buffer
+	if err := s.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := ReadSnapshot(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loaded
+}
+
+func TestTiledSnapshotResumesInNewModel(t *testing.T) {
+	data := MakeTiledData("../../internal/input/", "castle_data.json")
+	model := NewTiledModel(data, 20, 20, false)
+	model.SetSeed(3)
+	model.SetBacktracking(0)
+	model.Iterate(40)
+	snapshot := reloadSnapshot(t, model.Snapshot())
+
+	_, finished, want := model.Iterate(1000)
+
+	resumed := NewTiledModel(data, 20, 20, false)
+	resumed.SetBacktracking(0)
+	if err := resumed.Restore(snapshot); err != nil {
+		t.Fatal(err)
+	}
+	_, resumedFinished, got := resumed.Iterate(1000)
+
+	if finished != resumedFinished || want != got {
+		t.Fatalf("Resumed generation finished %v (success %v), want %v (%v).", resumedFinished, got, finished, want)
+	}
+	if !reflect.DeepEqual(model.State(), resumed.State()) {
+		t.Fatal("Resumed generation differs from the uninterrupted one.")
+	}
+}
+
+func TestUnseededSnapshotRestoresIntoNewModel(t *testing.T) {
+	data := MakeTiledData("../../internal/input/", "castle_data.json")
+	model := NewTiledModel(data, 20, 20, false)
+	model.Rng = rand.New(rand.NewSource(3)).Float64
+	model.RngSet = true
+	model.Iterate(40)
+	snapshot := reloadSnapshot(t, model.Snapshot())
+	if snapshot.Seeded {
+		t.Fatal("Expected a snapshot of an Rng set by hand to be unseeded.")
+	}
+
+	resumed := NewTiledModel(data, 20, 20, false)
+	resumed.SetBacktracking(0)
+	if err := resumed.Restore(snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if _, finished, success := resumed.Iterate(1000); !finished || !success {
+		t.Fatal("Failed to finish the restored generation.")
+	}
+}
+
+func TestOverlappingSnapshotScrubsBack(t *testing.T) {
+	inputImg, err := utils.LoadImage("../../internal/input/flowers.png")
+	if err != nil {
+		panic(err)
+	}
+
+	// Backtracks after the snapshot, see TestOverlappingBacktrackingRecovers
+	model := NewOverlappingModel(inputImg, 3, 48, 48, true, true, 2, true)
+	model.SetSeed(6)
+	model.SetBacktracking(0)
+	model.Iterate(50)
+	snapshot := model.Snapshot()
+
+	model.Iterate(5000)
+	first := model.State()
+	if !model.IsGenSuccess() || model.Backtracks == 0 {
+		t.Fatalf("Expected generation to succeed after backtracking, got success %v with %d backtracks.", model.IsGenSuccess(), model.Backtracks)
+	}
+
+	if err := model.Restore(reloadSnapshot(t, snapshot)); err != nil {
+		t.Fatal(err)
+	}
+	if model.IsGenSuccess() {
+		t.Fatal("Restoring did not undo the finished generation.")
+	}
+	model.Iterate(5000)
+	if !reflect.DeepEqual(first, model.State()) {
+		t.Fatal("Generation replayed from a snapshot differs from the original.")
+	}
+}
+
+func TestSnapshotRejectsMismatch(t *testing.T) {
+	data := MakeTiledData("../../internal/input/", "castle_data.json")
+	model := NewTiledModel(data, 20, 20, false)
+	model.SetSeed(1)
+	model.Iterate(1)
+
+	if err := NewTiledModel(data, 10, 20, false).Restore(model.Snapshot()); err == nil {
+		t.Error("Expected an error restoring into a smaller model.")
+	}
+
+	if _, err := ReadSnapshot(strings.NewReader(`{"version": 99}`)); err == nil {
+		t.Error("Expected an error reading an unknown version.")
+	}
+}
@@ -2,18 +2,23 @@ package wfc
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"image"
 	"os"
 	"strconv"
+	"strings"
 	"wfc/pkg/utils"
 )
 
 type RawData struct {
-	Path       string         `json:"path"`      // Path to tiles
-	Unique     bool           `json:"unique"`    // Default to false
-	TileSize   int            `json:"tileSize"`  // Default to 16
-	Tiles      []RawTile      `json:"tiles"`     //
-	Neighbours []RawNeighbour `json:"neighbors"` //
+	Path       string         `json:"path"`       // Path to tiles
+	Unique     bool           `json:"unique"`     // Default to false
+	TileSize   int            `json:"tileSize"`   // Width and height of square tiles
+	TileWidth  int            `json:"tileWidth"`  // Default to TileSize
+	TileHeight int            `json:"tileHeight"` // Default to TileSize
+	Tiles      []RawTile      `json:"tiles"`      //
+	Neighbours []RawNeighbour `json:"neighbors"`  //
 }
 
 type RawTile struct {
@@ -22,77 +27,251 @@ type RawTile struct {
 	Weight   float64 `json:"weight"`   // Default to 1
 }
 
-// Information on which tiles can be neighbors
+// Information on which tiles can be neighbors, either left and right
+// or top and bottom
 type RawNeighbour struct {
-	Left     string `json:"left"`     // Mathces Tile.Name
-	LeftNum  int    `json:"leftNum"`  // Default to 0
-	Right    string `json:"right"`    // Mathces Tile.Name
-	RightNum int    `json:"rightNum"` // Default to 0
+	Left      string `json:"left"`      // Mathces Tile.Name or "border"
+	LeftNum   int    `json:"leftNum"`   // Default to 0
+	Right     string `json:"right"`     // Mathces Tile.Name or "border"
+	RightNum  int    `json:"rightNum"`  // Default to 0
+	Top       string `json:"top"`       // Mathces Tile.Name or "border", only needed for non square tiles
+	TopNum    int    `json:"topNum"`    // Default to 0
+	Bottom    string `json:"bottom"`    // Mathces Tile.Name or "border", only needed for non square tiles
+	BottomNum int    `json:"bottomNum"` // Default to 0
 }
 
 type TiledData struct {
-	Unique    bool
-	TileSize  int
-	Tiles     []Tile
-	Neighbors []Neighbour
+	Unique     bool
+	TileSize   int // Deprecated: use TileWidth and TileHeight, only read when both are 0 and set by the loaders for square tiles
+	TileWidth  int
+	TileHeight int
+	Tiles      []Tile
+	Neighbors  []Neighbour
 }
 
+// Fills the tile width and height from TileSize when neither is set, and
+// TileSize from them for square tiles
+func (d TiledData) sized() TiledData {
+	if d.TileWidth == 0 && d.TileHeight == 0 {
+		d.TileWidth, d.TileHeight = d.TileSize, d.TileSize
+	}
+	d.TileSize = 0
+	if d.TileWidth == d.TileHeight {
+		d.TileSize = d.TileWidth
+	}
+	return d
+}
+
+// Same as LoadTiledData but panics on error
 func MakeTiledData(path string, file string) TiledData {
-	dataFile, err := os.ReadFile(path + file)
+	data, err := LoadTiledData(path, file)
 	if err != nil {
 		panic(err)
 	}
+	return data
+}
 
-	// Parse rd file
+// Reads the json data file and the tile images it references,
+// errors are a *FileError, *JSONError or *TileError
+func LoadTiledData(path string, file string) (TiledData, error) {
 	var rd RawData
-	if err := json.Unmarshal(dataFile, &rd); err != nil {
-		panic(err)
+	if err := readJSONData(path+file, &rd); err != nil {
+		return TiledData{}, err
 	}
 
-	// Marshal into data settings struct
-	tiles := make([]Tile, len(rd.Tiles))
-	for i, rt := range rd.Tiles {
-		imgs := make([]image.Image, 0)
-		if rd.Unique {
-			i := 1
-			for {
-				if img, err := utils.LoadImage(path + rd.Path + rt.Name + " " + strconv.Itoa(i) + ".png"); err == nil {
-					imgs = append(imgs, img)
-				} else {
-					break
-				}
-				i++
-			}
-		} else {
-			img, err := utils.LoadImage(path + rd.Path + rt.Name + ".png")
-			if err != nil {
-				panic(err)
-			}
-			imgs = append(imgs, img)
-		}
+	tiles, err := loadRawTiles(path+rd.Path, rd.Tiles, rd.Unique)
+	if err != nil {
+		return TiledData{}, err
+	}
+
+	if rd.TileWidth == 0 {
+		rd.TileWidth = rd.TileSize
+	}
+	if rd.TileHeight == 0 {
+		rd.TileHeight = rd.TileSize
+	}
+
+	return TiledData{
+		Unique:     rd.Unique,
+		TileWidth:  rd.TileWidth,
+		TileHeight: rd.TileHeight,
+		Tiles:      tiles,
+		Neighbors:  rawNeighbours(rd.Neighbours),
+	}.sized(), nil
+}
 
-		weight := rt.Weight
-		if weight == 0 {
-			weight = 1
+// Reads a json data file into rd, errors are a *FileError or *JSONError
+func readJSONData(dataPath string, rd any) error {
+	dataFile, err := os.ReadFile(dataPath)
+	if err != nil {
+		return &FileError{Path: dataPath, Err: err}
+	}
+	if err := json.Unmarshal(dataFile, rd); err != nil {
+		return newJSONError(dataPath, err)
+	}
+	return nil
+}
+
+// Converts the raw tiles, loading their images from dir, errors are a
+// *TileError
+func loadRawTiles(dir string, rts []RawTile, unique bool) ([]Tile, error) {
+	tiles := make([]Tile, len(rts))
+	for i, rt := range rts {
+		imgs, err := loadTileImages(dir, rt.Name, unique)
+		if err != nil {
+			return nil, err
 		}
 
 		tiles[i] = Tile{
 			Name:     rt.Name,
 			Sym:      rt.Symmetry,
-			Weight:   weight,
+			Weight:   rawWeight(rt.Weight),
 			Variants: imgs,
 		}
 	}
+	return tiles, nil
+}
 
-	neighbours := make([]Neighbour, len(rd.Neighbours))
-	for i, rn := range rd.Neighbours {
+func rawNeighbours(rns []RawNeighbour) []Neighbour {
+	neighbours := make([]Neighbour, len(rns))
+	for i, rn := range rns {
 		neighbours[i] = Neighbour(rn)
 	}
+	return neighbours
+}
 
-	return TiledData{
-		Unique:    rd.Unique,
-		TileSize:  rd.TileSize,
-		Tiles:     tiles,
-		Neighbors: neighbours,
+// Weight of a raw tile, which defaults to 1
+func rawWeight(weight float64) float64 {
+	if weight == 0 {
+		return 1
+	}
+	return weight
+}
+
+// Loads the image of a tile, or every numbered image in unique mode
+// starting from "name 1.png", errors are a *TileError
+func loadTileImages(dir, name string, unique bool) ([]image.Image, error) {
+	imgs := make([]image.Image, 0)
+	if !unique {
+		imgPath := dir + name + ".png"
+		img, err := utils.LoadImage(imgPath)
+		if err != nil {
+			return nil, &TileError{Tile: name, Path: imgPath, Err: err}
+		}
+		return append(imgs, img), nil
+	}
+
+	for i := 1; ; i++ {
+		imgPath := dir + name + " " + strconv.Itoa(i) + ".png"
+		img, err := utils.LoadImage(imgPath)
+		if err != nil {
+			if i == 1 {
+				return nil, &TileError{Tile: name, Path: imgPath, Err: err}
+			}
+			return imgs, nil
+		}
+		imgs = append(imgs, img)
 	}
 }
+
+func newJSONError(path string, err error) *JSONError {
+	e := &JSONError{Path: path, Err: err}
+
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+	)
+	if errors.As(err, &syntaxErr) {
+		e.Offset = syntaxErr.Offset
+	} else if errors.As(err, &typeErr) {
+		e.Field = typeErr.Field
+		e.Offset = typeErr.Offset
+	}
+
+	return e
+}
+
+// A single problem found by Validate
+type ValidationIssue struct {
+	Tile    string // Name of the tile concerned, empty if not about a single tile
+	Field   string // Offending field, e.g. neighbors[3].left
+	Message string // Description of the problem
+}
+
+func (i ValidationIssue) String() string {
+	return i.Field + ": " + i.Message
+}
+
+// Every problem found by Validate
+type ValidationError struct {
+	Issues []ValidationIssue
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "wfc: %d problem(s) in tiled data", len(e.Issues))
+	for _, issue := range e.Issues {
+		b.WriteString("\n\t")
+		b.WriteString(issue.String())
+	}
+	return b.String()
+}
+
+// Names of the propagator directions, where the neighbour sits
+var directionNames = [4]string{"left", "below", "right", "above"}
+
+// Checks the data for problems NewTiledModel would silently accept or
+// panic on, reporting all of them at once as a *ValidationError
+func (d TiledData) Validate() error {
+	v := newValidator()
+
+	d = d.sized()
+	v.size(d.TileWidth, d.TileHeight)
+	square := d.TileWidth == d.TileHeight
+
+	names := make([]string, len(d.Tiles))
+	for i, tile := range d.Tiles {
+		field := fmt.Sprintf("tiles[%d]", i)
+		names[i] = tile.Name
+
+		if tile.Name == BorderTile {
+			v.report(tile.Name, field+".name", "%q is reserved for the output border", BorderTile)
+		}
+		cardinality, _, _, ok := tileSymmetry(tile.Sym)
+		if !square {
+			cardinality, _, _ = tileKleinSymmetry(tile.Sym)
+		}
+		v.tile(field, tile.Name, tile.Weight, tile.Sym, cardinality, ok, tileSymmetries)
+		v.images(field, tile, d.Unique, d.TileWidth, d.TileHeight)
+	}
+
+	// Only a border the model will add can be referenced
+	if _, border := withBorder(d.Tiles, d.Neighbors); border {
+		v.cardinalities[BorderTile] = 1
+	}
+	// Square tiles look nums up in their 8 transforms, like data.xml
+	actions := 0
+	if square {
+		actions = 8
+	}
+	valid := v.neighbours(d.Neighbors, actions)
+
+	tiles, _ := withBorder(d.Tiles, valid)
+	action, first := tileActions(tiles, square)
+	propagator := tilePropagator(action, first, valid, square)
+
+	where := make([]string, len(directionNames))
+	for dir, name := range directionNames {
+		where[dir] = name + " it"
+	}
+	v.stranded(names, first, where, func(dir, t int) bool {
+		for t1 := range action {
+			if propagator[dir][t][t1] {
+				return true
+			}
+		}
+		return false
+	})
+
+	return v.err()
+}
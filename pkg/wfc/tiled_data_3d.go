@@ -0,0 +1,135 @@
+package wfc
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+type RawData3D struct {
+	Tiles      []RawTile3D    `json:"tiles"`     //
+	Neighbours []RawNeighbour `json:"neighbors"` // Left and right rules are horizontal, top and bottom rules vertical
+}
+
+type RawTile3D struct {
+	Name     string  `json:"name"`     // Name used to identify the tile
+	Symmetry string  `json:"symmetry"` // Symmetry around the vertical axis, default to ""
+	Weight   float64 `json:"weight"`   // Default to 1
+	Color    string  `json:"color"`    // Voxel colour as #rrggbb, empty tiles are not exported
+}
+
+type TiledData3D struct {
+	Tiles     []Tile3D
+	Neighbors []Neighbour
+}
+
+type Tile3D struct {
+	Name   string
+	Sym    string
+	Weight float64
+	Color  color.Color // Nil for empty tiles
+}
+
+// Same as LoadTiledData3D but panics on error
+func MakeTiledData3D(path string, file string) TiledData3D {
+	data, err := LoadTiledData3D(path, file)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// Reads the json data file of a voxel model, errors are a *FileError
+// or *JSONError
+func LoadTiledData3D(path string, file string) (TiledData3D, error) {
+	dataPath := path + file
+	var rd RawData3D
+	if err := readJSONData(dataPath, &rd); err != nil {
+		return TiledData3D{}, err
+	}
+
+	tiles := make([]Tile3D, len(rd.Tiles))
+	for i, rt := range rd.Tiles {
+		var c color.Color
+		if rt.Color != "" {
+			var err error
+			c, err = parseColor(rt.Color)
+			if err != nil {
+				return TiledData3D{}, &JSONError{Path: dataPath, Field: fmt.Sprintf("tiles[%d].color", i), Err: err}
+			}
+		}
+
+		tiles[i] = Tile3D{
+			Name:   rt.Name,
+			Sym:    rt.Symmetry,
+			Weight: rawWeight(rt.Weight),
+			Color:  c,
+		}
+	}
+
+	return TiledData3D{
+		Tiles:     tiles,
+		Neighbors: rawNeighbours(rd.Neighbours),
+	}, nil
+}
+
+// Parses #rrggbb
+func parseColor(s string) (color.Color, error) {
+	hex, ok := strings.CutPrefix(s, "#")
+	if !ok || len(hex) != 6 {
+		return nil, fmt.Errorf("want #rrggbb, got %q", s)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("want #rrggbb, got %q", s)
+	}
+	return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 255}, nil
+}
+
+// Names of the horizontal 3D propagator directions, where the neighbour
+// sits, y grows towards the front
+var directionNames3D = [4]string{"left", "in front of", "right", "behind"}
+
+// Checks the data for problems NewTiledModel3D would silently accept or
+// panic on, reporting all of them at once as a *ValidationError
+func (d TiledData3D) Validate() error {
+	v := newValidator()
+
+	names := make([]string, len(d.Tiles))
+	for i, tile := range d.Tiles {
+		names[i] = tile.Name
+		cardinality, _, _, ok := tileSymmetry(tile.Sym)
+		v.tile(fmt.Sprintf("tiles[%d]", i), tile.Name, tile.Weight, tile.Sym, cardinality, ok, tileSymmetries)
+	}
+	valid := v.neighbours(d.Neighbors, 8)
+
+	// Vertical neighbours are optional, tiles without one only fit the
+	// top or bottom layer
+	action, first := tileActions(d.tiles(), true)
+	propagator := tilePropagator3D(action, first, valid)
+
+	where := make([]string, len(directionNames3D))
+	for dir, name := range directionNames3D {
+		where[dir] = name + " it"
+	}
+	v.stranded(names, first, where, func(dir, t int) bool {
+		for t1 := range action {
+			if propagator[dir][t][t1] {
+				return true
+			}
+		}
+		return false
+	})
+
+	return v.err()
+}
+
+// The tiles as 2D tiles, for sharing the symmetry tables
+func (d TiledData3D) tiles() []Tile {
+	tiles := make([]Tile, len(d.Tiles))
+	for i, tile := range d.Tiles {
+		tiles[i] = Tile{Name: tile.Name, Sym: tile.Sym, Weight: tile.Weight}
+	}
+	return tiles
+}
@@ -0,0 +1,156 @@
+package wfc
+
+import (
+	"errors"
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeDataFile(t *testing.T, contents string) string {
+	dir := t.TempDir() + "/"
+	if err := os.WriteFile(filepath.Join(dir, "data.json"), []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestLoadTiledDataMissingFile(t *testing.T) {
+	_, err := LoadTiledData(t.TempDir()+"/", "missing.json")
+
+	var fileErr *FileError
+	if !errors.As(err, &fileErr) {
+		t.Fatalf("Expected a *FileError, got %v.", err)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Expected the error to wrap os.ErrNotExist, got %v.", err)
+	}
+}
+
+func TestLoadTiledDataBadJSON(t *testing.T) {
+	dir := writeDataFile(t, `{ "tileSize": "seven" }`)
+	_, err := LoadTiledData(dir, "data.json")
+
+	var jsonErr *JSONError
+	if !errors.As(err, &jsonErr) {
+		t.Fatalf("Expected a *JSONError, got %v.", err)
+	}
+	if jsonErr.Field != "tileSize" {
+		t.Fatalf("Expected field tileSize, got %q.", jsonErr.Field)
+	}
+}
+
+func TestLoadTiledDataMissingTile(t *testing.T) {
+	dir := writeDataFile(t, `{ "tileSize": 7, "tiles": [ { "name": "nope" } ] }`)
+	_, err := LoadTiledData(dir, "data.json")
+
+	var tileErr *TileError
+	if !errors.As(err, &tileErr) {
+		t.Fatalf("Expected a *TileError, got %v.", err)
+	}
+	if tileErr.Tile != "nope" || tileErr.Path != dir+"nope.png" {
+		t.Fatalf("Unexpected tile error %v.", tileErr)
+	}
+}
+
+func TestValidateReportsAllIssues(t *testing.T) {
+	square := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	wide := image.NewRGBA(image.Rect(0, 0, 3, 2))
+
+	data := TiledData{
+		TileWidth:  2,
+		TileHeight: 2,
+		Tiles: []Tile{
+			{Name: "a", Sym: "X", Weight: 1, Variants: []image.Image{square}},
+			{Name: "b", Sym: "Q", Weight: 1, Variants: []image.Image{wide}},
+		},
+		Neighbors: []Neighbour{
+			{Left: "a", Right: "a"},
+			{Left: "a", Right: "c"},
+			{Left: "a", LeftNum: 8, Right: "b"},
+		},
+	}
+
+	err := data.Validate()
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected a *ValidationError, got %v.", err)
+	}
+
+	fields := make(map[string]bool)
+	for _, issue := range validationErr.Issues {
+		fields[issue.Field] = true
+	}
+
+	for _, field := range []string{
+		"tiles[1].symmetry",
+		"tiles[1].variants[0]",
+		"neighbors[1].right",
+		"neighbors[2].leftNum",
+		"tiles[1]",
+	} {
+		if !fields[field] {
+			t.Errorf("Expected an issue for %s, got %v.", field, validationErr)
+		}
+	}
+	if fields["tiles[0]"] {
+		t.Errorf("Tile a has neighbours in every direction, got %v.", validationErr)
+	}
+}
+
+func TestValidateNumAliases(t *testing.T) {
+	square := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	wide := image.NewRGBA(image.Rect(0, 0, 4, 2))
+
+	// Square tiles look nums up in their 8 transforms, so "a 5" is the
+	// mirrored a even though X has a single variant
+	data := TiledData{
+		TileWidth:  2,
+		TileHeight: 2,
+		Tiles:      []Tile{{Name: "a", Sym: "X", Weight: 1, Variants: []image.Image{square}}},
+		Neighbors:  []Neighbour{{Left: "a", LeftNum: 5, Right: "a", RightNum: 7}},
+	}
+	if err := data.Validate(); err != nil {
+		t.Fatalf("Expected nums up to 7 to be accepted, got %v.", err)
+	}
+	NewTiledModel(data, 4, 4, false)
+
+	// Non square tiles only have their variants
+	data = TiledData{
+		TileWidth:  4,
+		TileHeight: 2,
+		Tiles:      []Tile{{Name: "a", Sym: "X", Weight: 1, Variants: []image.Image{wide}}},
+		Neighbors:  []Neighbour{{Left: "a", LeftNum: 1, Right: "a"}},
+	}
+	var validationErr *ValidationError
+	if !errors.As(data.Validate(), &validationErr) || validationErr.Issues[0].Field != "neighbors[0].leftNum" {
+		t.Fatalf("Expected an issue for neighbors[0].leftNum, got %v.", data.Validate())
+	}
+}
+
+func TestValidateSampleData(t *testing.T) {
+	for _, file := range []string{"castle_data.json", "pipe_data.json"} {
+		if err := MakeTiledData("../../internal/input/", file).Validate(); err != nil {
+			t.Errorf("Expected %s to be valid, got %v.", file, err)
+		}
+	}
+}
+
+func TestDeprecatedTileSize(t *testing.T) {
+	data := MakeTiledData("../../internal/input/", "castle_data.json")
+	if data.TileSize != 7 {
+		t.Fatalf("Expected TileSize 7 for square tiles, got %d.", data.TileSize)
+	}
+
+	// Data built by hand with only TileSize still works
+	data.TileWidth, data.TileHeight = 0, 0
+	if err := data.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	model := NewTiledModel(data, 5, 5, false)
+	if model.TileSize != 7 || model.TileWidth != 7 || model.TileHeight != 7 {
+		t.Fatalf("Expected 7x7 tiles, got size %d, %dx%d.", model.TileSize, model.TileWidth, model.TileHeight)
+	}
+}
@@ -1,15 +1,25 @@
 package wfc
 
 import (
+	"context"
+	"fmt"
 	"image"
 	"image/color"
+	"strconv"
+	"strings"
 )
 
 type TiledModel struct {
 	*BaseModel
-	TileSize   int
+	TileSize   int // Deprecated: use TileWidth and TileHeight, set for square tiles only
+	TileWidth  int
+	TileHeight int
 	Tiles      []TilePattern
+	TileOf     []int    // Index into the data tiles of the tile each pattern (t) is a variant of
+	TileNames  []string // Name of each data tile
+	Variant    []int    // Variant of its tile each pattern is, anticlockwise quarter turns (plus 4 when mirrored) for square tiles, see tileKleinSymmetry otherwise
 	Propagator [][][]bool
+	Border     [][]bool // Variants allowed next to the border of a non periodic output [d][t], nil for no border rules
 }
 
 type Tile struct {
@@ -20,10 +30,19 @@ type Tile struct {
 }
 
 type Neighbour struct {
-	Left     string
-	LeftNum  int
-	Right    string
-	RightNum int
+	Left      string
+	LeftNum   int
+	Right     string
+	RightNum  int
+	Top       string
+	TopNum    int
+	Bottom    string
+	BottomNum int
+}
+
+// Returns whether the rule is between a top and a bottom tile
+func (n Neighbour) Vertical() bool {
+	return n.Top != "" || n.Bottom != ""
 }
 
 type TilePattern []color.Color
@@ -31,6 +50,7 @@ type TilePattern []color.Color
 type Inversion func(int) int
 
 func NewTiledModel(data TiledData, width int, height int, periodic bool) *TiledModel {
+	data = data.sized()
 
 	// Initialize m
 	m := &TiledModel{
@@ -40,18 +60,21 @@ func NewTiledModel(data TiledData, width int, height int, periodic bool) *TiledM
 			Periodic:   periodic,
 			Stationary: make([]float64, 0),
 		},
-		TileSize: data.TileSize,
-		Tiles:    make([]TilePattern, 0),
+		TileSize:   data.TileSize,
+		TileWidth:  data.TileWidth,
+		TileHeight: data.TileHeight,
+		Tiles:      make([]TilePattern, 0),
 	}
 
-	first := make(map[string]int)
-	action := make([][]int, 0)
+	square := m.TileWidth == m.TileHeight
+	tiles, border := withBorder(data.Tiles, data.Neighbors)
+	action, first := tileActions(tiles, square)
 
 	tile := func(transformer func(x int, y int) color.Color) TilePattern {
-		result := make(TilePattern, m.TileSize*m.TileSize)
-		for y := 0; y < m.TileSize; y++ {
-			for x := 0; x < m.TileSize; x++ {
-				result[x+y*m.TileSize] = transformer(x, y)
+		result := make(TilePattern, m.TileWidth*m.TileHeight)
+		for y := 0; y < m.TileHeight; y++ {
+			for x := 0; x < m.TileWidth; x++ {
+				result[x+y*m.TileWidth] = transformer(x, y)
 			}
 		}
 		return result
@@ -59,90 +82,37 @@ func NewTiledModel(data TiledData, width int, height int, periodic bool) *TiledM
 
 	rotate := func(p TilePattern) TilePattern {
 		return tile(func(x, y int) color.Color {
-			return p[m.TileSize-1-y+x*m.TileSize]
+			return p[m.TileWidth-1-y+x*m.TileWidth]
 		})
 	}
 
-	for i := 0; i < len(data.Tiles); i++ {
-		current := data.Tiles[i]
-		var (
-			cardinality int
-			inv1        Inversion
-			inv2        Inversion
-		)
-
-		switch current.Sym {
-		case "L":
-			cardinality = 4
-			inv1 = func(i int) int {
-				return (i + 1) % 4
-			}
-			inv2 = func(i int) int {
-				if i%2 == 0 {
-					return i + 1
-				}
-				return i - 1
-			}
-		case "T":
-			cardinality = 4
-			inv1 = func(i int) int {
-				return (i + 1) % 4
-			}
-			inv2 = func(i int) int {
-				if i%2 == 0 {
-					return i
-				}
-				return 4 - i
-			}
-		case "I":
-			cardinality = 2
-			inv1 = func(i int) int {
-				return 1 - i
-			}
-			inv2 = func(i int) int {
-				return i
-			}
-		case "\\":
-			cardinality = 2
-			inv1 = func(i int) int {
-				return 1 - i
-			}
-			inv2 = func(i int) int {
-				return 1 - i
-			}
-		case "X":
-			cardinality = 1
-			inv1 = func(i int) int {
-				return i
-			}
-			inv2 = func(i int) int {
-				return i
-			}
-		default:
-			cardinality = 1
-			inv1 = func(i int) int {
-				return i
+	reflect := func(p TilePattern) TilePattern {
+		return tile(func(x, y int) color.Color {
+			return p[m.TileWidth-1-x+y*m.TileWidth]
+		})
+	}
+
+	// Transforms of non square tiles by kleinTransforms index
+	transform := func(p TilePattern, g int) TilePattern {
+		return tile(func(x, y int) color.Color {
+			if g == 1 || g == 2 {
+				x = m.TileWidth - 1 - x
 			}
-			inv2 = func(i int) int {
-				return i
+			if g == 1 || g == 3 {
+				y = m.TileHeight - 1 - y
 			}
-		}
-
-		m.T = len(action)
-		first[current.Name] = m.T
+			return p[x+y*m.TileWidth]
+		})
+	}
 
-		for t := 0; t < cardinality; t++ {
-			action = append(action, []int{
-				m.T + t,
-				m.T + inv1(t),
-				m.T + inv1(inv1(t)),
-				m.T + inv1(inv1(inv1(t))),
-				m.T + inv2(t),
-				m.T + inv2(inv1(t)),
-				m.T + inv2(inv1(inv1(t))),
-				m.T + inv2(inv1(inv1(inv1(t)))),
-			})
+	for i := 0; i < len(data.Tiles); i++ {
+		current := data.Tiles[i]
+		cardinality, _, _, _ := tileSymmetry(current.Sym)
+		if !square {
+			cardinality, _, _ = tileKleinSymmetry(current.Sym)
 		}
+		m.TileNames = append(m.TileNames, current.Name)
+		start := len(m.Tiles)
 
 		if data.Unique {
 			for t := 0; t < cardinality; t++ {
@@ -157,172 +127,166 @@ func NewTiledModel(data TiledData, width int, height int, periodic bool) *TiledM
 				return img.At(x, y)
 			}))
 
-			for t := 1; t < cardinality; t++ {
-				m.Tiles = append(m.Tiles, rotate(m.Tiles[m.T+t-1]))
+			if square {
+				// Variants past the fourth are mirrored turns
+				for t := 1; t < cardinality; t++ {
+					if t < 4 {
+						m.Tiles = append(m.Tiles, rotate(m.Tiles[start+t-1]))
+					} else {
+						m.Tiles = append(m.Tiles, reflect(m.Tiles[start+t-4]))
+					}
+				}
+			} else {
+				_, _, representative := tileKleinSymmetry(current.Sym)
+				for t := 1; t < cardinality; t++ {
+					m.Tiles = append(m.Tiles, transform(m.Tiles[start], representative(t)))
+				}
 			}
 		}
 
 		for t := 0; t < cardinality; t++ {
 			m.Stationary = append(m.Stationary, current.Weight)
+			m.TileOf = append(m.TileOf, i)
+			m.Variant = append(m.Variant, t)
 		}
 	}
 
 	m.T = len(action)
-	m.Propagator = make([][][]bool, 4)
-
-	for i := 0; i < 4; i++ {
-		m.Propagator[i] = make([][]bool, m.T)
-		for t := 0; t < m.T; t++ {
-			m.Propagator[i][t] = make([]bool, m.T)
-			for t2 := 0; t2 < m.T; t2++ {
-				m.Propagator[i][t][t2] = false
+	m.Propagator = tilePropagator(action, first, data.Neighbors, square)
+
+	// The border is never placed, it only decides what may touch the edges
+	if border {
+		m.T--
+		m.Border = make([][]bool, 4)
+		for d := range m.Propagator {
+			m.Border[d] = m.Propagator[d][m.T][:m.T]
+			m.Propagator[d] = m.Propagator[d][:m.T]
+			for t := range m.Propagator[d] {
+				m.Propagator[d][t] = m.Propagator[d][t][:m.T]
 			}
 		}
 	}
 
-	m.Wave = make([][][]bool, m.Fmx)
-	m.Changes = make([][]bool, m.Fmx)
+	m.wave = NewWave(m.Fmx*m.Fmy, m.T)
 
-	for x := 0; x < m.Fmx; x++ {
-		m.Wave[x] = make([][]bool, m.Fmy)
-		m.Changes[x] = make([]bool, m.Fmy)
-		for y := 0; y < m.Fmy; y++ {
-			m.Wave[x][y] = make([]bool, m.T)
-		}
-	}
+	m.SetTopology(Grid{m.Fmx, m.Fmy, m.Periodic, tileOffsets[:]}, tileAdjacency(m.Propagator))
 
-	for i := 0; i < len(data.Neighbors); i++ {
-		neighbor := data.Neighbors[i]
+	return m
+}
 
-		l := action[first[neighbor.Left]][neighbor.LeftNum]
-		d := action[l][1]
-		r := action[first[neighbor.Right]][neighbor.RightNum]
-		u := action[r][1]
+func (model *TiledModel) OnBoundary(x int, y int) bool {
+	return false
+}
 
-		m.Propagator[0][r][l] = true
-		m.Propagator[0][action[r][6]][action[l][6]] = true
-		m.Propagator[0][action[l][4]][action[r][4]] = true
-		m.Propagator[0][action[l][2]][action[r][2]] = true
+func (model *TiledModel) Propagate() bool {
+	return model.PropagateBase(model)
+}
 
-		m.Propagator[1][u][d] = true
-		m.Propagator[1][action[d][6]][action[u][6]] = true
-		m.Propagator[1][action[u][4]][action[d][4]] = true
-		m.Propagator[1][action[d][2]][action[u][2]] = true
+// Returns the patterns of a tile, either every variant of "name" or
+// the single variant "name n"
+func (m *TiledModel) TilePatterns(tile string) ([]int, error) {
+	name, variant := tile, -1
+	if i := strings.LastIndexByte(tile, ' '); i != -1 && !m.hasTile(tile) {
+		n, err := strconv.Atoi(tile[i+1:])
+		if err == nil {
+			name, variant = tile[:i], n
+		}
 	}
 
+	patterns := make([]int, 0)
+	first := -1
 	for t := 0; t < m.T; t++ {
-		for t2 := 0; t2 < m.T; t2++ {
-			m.Propagator[2][t][t2] = m.Propagator[0][t2][t]
-			m.Propagator[3][t][t2] = m.Propagator[1][t2][t]
+		if m.TileNames[m.TileOf[t]] != name {
+			continue
+		}
+		if first == -1 {
+			first = t
+		}
+		if variant == -1 || t-first == variant {
+			patterns = append(patterns, t)
 		}
 	}
 
-	return m
+	if first == -1 {
+		return nil, fmt.Errorf("wfc: unknown tile %q", name)
+	}
+	if len(patterns) == 0 {
+		return nil, fmt.Errorf("wfc: tile %q has no variant %d", name, variant)
+	}
+	return patterns, nil
 }
 
-func (model *TiledModel) OnBoundary(x int, y int) bool {
+func (m *TiledModel) hasTile(name string) bool {
+	for _, n := range m.TileNames {
+		if n == name {
+			return true
+		}
+	}
 	return false
 }
 
-func (model *TiledModel) Propagate() bool {
-	change := false
-
-	for x2 := 0; x2 < model.Fmx; x2++ {
-		for y2 := 0; y2 < model.Fmy; y2++ {
-			for d := 0; d < 4; d++ {
-				x1 := x2
-				y1 := y2
-
-				if d == 0 {
-					if x2 == 0 {
-						if !model.Periodic {
-							continue
-						} else {
-							x1 = model.Fmx - 1
-						}
-					} else {
-						x1 = x2 - 1
-					}
-				} else if d == 1 {
-					if y2 == model.Fmy-1 {
-						if !model.Periodic {
-							continue
-						} else {
-							y1 = 0
-						}
-					} else {
-						y1 = y2 + 1
-					}
-				} else if d == 2 {
-					if x2 == model.Fmx-1 {
-						if !model.Periodic {
-							continue
-						} else {
-							x1 = 0
-						}
-					} else {
-						x1 = x2 + 1
-					}
-				} else {
-					if y2 == 0 {
-						if !model.Periodic {
-							continue
-						} else {
-							y1 = model.Fmy - 1
-						}
-					} else {
-						y1 = y2 - 1
-					}
-				}
-
-				if !model.Changes[x1][y1] {
-					continue
-				}
+// Restricts (x, y) to the given tiles, see TilePatterns for the names
+// accepted, kept across Clear and propagated before the first observation
+func (m *TiledModel) Constrain(x, y int, tiles ...string) error {
+	patterns := make([]int, 0)
+	for _, tile := range tiles {
+		p, err := m.TilePatterns(tile)
+		if err != nil {
+			return err
+		}
+		patterns = append(patterns, p...)
+	}
+	return m.ConstrainPatterns(x, y, patterns...)
+}
 
-				for t2 := 0; t2 < model.T; t2++ {
-					if model.Wave[x2][y2][t2] {
-						b := false
+func (m *TiledModel) Clear() {
+	m.ClearBase(m)
 
-						for t1 := 0; t1 < model.T && !b; t1++ {
-							if model.Wave[x1][y1][t1] {
-								b = model.Propagator[d][t2][t1]
-							}
-						}
+	if m.Border == nil || m.Periodic {
+		return
+	}
 
-						if !b {
-							model.Wave[x2][y2][t2] = false
-							model.Changes[x2][y2] = true
-							change = true
-						}
+	for y := 0; y < m.Fmy; y++ {
+		for x := 0; x < m.Fmx; x++ {
+			for d, offset := range tileOffsets {
+				x2, y2 := x+offset.X, y+offset.Y
+				if x2 >= 0 && y2 >= 0 && x2 < m.Fmx && y2 < m.Fmy {
+					continue
+				}
+				for t := 0; t < m.T; t++ {
+					if !m.Border[d][t] && m.Allowed(x, y, t) {
+						m.ban(x, y, t)
 					}
 				}
 			}
 		}
 	}
 
-	return change
+	for m.Propagate() {
+		// Empty loop
+	}
 }
 
-func (m *TiledModel) Clear() {
-	m.ClearBase(m)
+// Puts the model back in the state of a snapshot, see Snapshot
+func (m *TiledModel) Restore(s *Snapshot) error {
+	return m.BaseModel.Restore(m, s)
 }
 
 func (model *TiledModel) RenderCompleteImage() image.Image {
-	output := make([][]color.Color, model.Fmx*model.TileSize)
+	output := make([][]color.Color, model.Fmx*model.TileWidth)
 	for i := range output {
-		output[i] = make([]color.Color, model.Fmy*model.TileSize)
+		output[i] = make([]color.Color, model.Fmy*model.TileHeight)
 	}
 
 	for y := 0; y < model.Fmy; y++ {
 		for x := 0; x < model.Fmx; x++ {
-			for yt := 0; yt < model.TileSize; yt++ {
-				for xt := 0; xt < model.TileSize; xt++ {
-					for t := 0; t < model.T; t++ {
-						if model.Wave[x][y][t] {
-							output[x*model.TileSize+xt][y*model.TileSize+yt] = model.Tiles[t][yt*model.TileSize+xt]
-							break
-						}
-					}
+			t := model.wave.Cell(x + y*model.Fmx).First()
+			if t == -1 {
+				continue
+			}
+			for yt := 0; yt < model.TileHeight; yt++ {
+				for xt := 0; xt < model.TileWidth; xt++ {
+					output[x*model.TileWidth+xt][y*model.TileHeight+yt] = model.Tiles[t][yt*model.TileWidth+xt]
 				}
 			}
 		}
@@ -331,30 +295,29 @@ func (model *TiledModel) RenderCompleteImage() image.Image {
 }
 
 func (model *TiledModel) RenderIncompleteImage() image.Image {
-	output := make([][]color.Color, model.Fmx*model.TileSize)
+	output := make([][]color.Color, model.Fmx*model.TileWidth)
 	for i := range output {
-		output[i] = make([]color.Color, model.Fmy*model.TileSize)
+		output[i] = make([]color.Color, model.Fmy*model.TileHeight)
 	}
 
 	for y := 0; y < model.Fmy; y++ {
 		for x := 0; x < model.Fmx; x++ {
-			amount := 0
+			amount := model.Remaining(x, y)
 			sum := 0.0
-			for t := 0; t < len(model.Wave[x][y]); t++ {
-				if model.Wave[x][y][t] {
-					amount += 1
+			for t := 0; t < model.T; t++ {
+				if model.Allowed(x, y, t) {
 					sum += model.Stationary[t]
 				}
 			}
-			for yt := 0; yt < model.TileSize; yt++ {
-				for xt := 0; xt < model.TileSize; xt++ {
+			for yt := 0; yt < model.TileHeight; yt++ {
+				for xt := 0; xt < model.TileWidth; xt++ {
 					if amount == model.T {
-						output[x*model.TileSize+xt][y*model.TileSize+yt] = color.RGBA{127, 127, 127, 255}
+						output[x*model.TileWidth+xt][y*model.TileHeight+yt] = color.RGBA{127, 127, 127, 255}
 					} else {
 						sR, sG, sB, sA := 0.0, 0.0, 0.0, 0.0
 						for t := 0; t < model.T; t++ {
-							if model.Wave[x][y][t] {
-								r, g, b, a := model.Tiles[t][yt*model.TileSize+xt].RGBA()
+							if model.Allowed(x, y, t) {
+								r, g, b, a := model.Tiles[t][yt*model.TileWidth+xt].RGBA()
 								sR += float64(r) * model.Stationary[t]
 								sG += float64(g) * model.Stationary[t]
 								sB += float64(b) * model.Stationary[t]
@@ -365,7 +328,7 @@ func (model *TiledModel) RenderIncompleteImage() image.Image {
 						uG := uint8(int(sG/sum) >> 8)
 						uB := uint8(int(sB/sum) >> 8)
 						uA := uint8(int(sA/sum) >> 8)
-						output[x*model.TileSize+xt][y*model.TileSize+yt] = color.RGBA{uR, uG, uB, uA}
+						output[x*model.TileWidth+xt][y*model.TileHeight+yt] = color.RGBA{uR, uG, uB, uA}
 					}
 				}
 			}
@@ -391,3 +354,345 @@ func (m *TiledModel) Generate() (image.Image, bool) {
 	m.BaseModel.Generate(m)
 	return m.Render(), m.IsGenSuccess()
 }
+
+// Symmetry classes known to tileSymmetry, for error messages
+const tileSymmetries = "L, T, I, \\, F, X"
+
+// Returns the number of variants of a symmetry class along with the
+// rotation (inv1) and reflection (inv2) of a variant, ok is false for
+// unknown classes which are treated as having no symmetry
+func tileSymmetry(sym string) (cardinality int, inv1 Inversion, inv2 Inversion, ok bool) {
+	ok = true
+
+	switch sym {
+	case "F":
+		cardinality = 8
+		inv1 = func(i int) int {
+			if i < 4 {
+				return (i + 1) % 4
+			}
+			return 4 + (i+3)%4
+		}
+		inv2 = func(i int) int {
+			if i < 4 {
+				return i + 4
+			}
+			return i - 4
+		}
+	case "L":
+		cardinality = 4
+		inv1 = func(i int) int {
+			return (i + 1) % 4
+		}
+		inv2 = func(i int) int {
+			if i%2 == 0 {
+				return i + 1
+			}
+			return i - 1
+		}
+	case "T":
+		cardinality = 4
+		inv1 = func(i int) int {
+			return (i + 1) % 4
+		}
+		inv2 = func(i int) int {
+			if i%2 == 0 {
+				return i
+			}
+			return 4 - i
+		}
+	case "I":
+		cardinality = 2
+		inv1 = func(i int) int {
+			return 1 - i
+		}
+		inv2 = identity
+	case "\\":
+		cardinality = 2
+		inv1 = func(i int) int {
+			return 1 - i
+		}
+		inv2 = func(i int) int {
+			return 1 - i
+		}
+	case "X", "":
+		cardinality = 1
+		inv1 = identity
+		inv2 = identity
+	default:
+		cardinality = 1
+		inv1 = identity
+		inv2 = identity
+		ok = false
+	}
+
+	return cardinality, inv1, inv2, ok
+}
+
+func identity(i int) int {
+	return i
+}
+
+// Name of the virtual tile surrounding a non periodic output, neighbour
+// rules naming it decide which tiles may touch each edge
+const BorderTile = "border"
+
+// Appends the virtual border tile when a neighbour rule names it
+func withBorder(tiles []Tile, neighbours []Neighbour) ([]Tile, bool) {
+	for _, tile := range tiles {
+		if tile.Name == BorderTile {
+			return tiles, false
+		}
+	}
+	for _, n := range neighbours {
+		if n.Left == BorderTile || n.Right == BorderTile || n.Top == BorderTile || n.Bottom == BorderTile {
+			border := Tile{Name: BorderTile, Sym: "X", Weight: 1}
+			return append(tiles[:len(tiles):len(tiles)], border), true
+		}
+	}
+	return tiles, false
+}
+
+// Returns the number of variants of a symmetry class for non square
+// tiles, which can only be turned half way or flipped, along with the
+// variant each transform turns variant 0 into and back
+func tileKleinSymmetry(sym string) (cardinality int, variant func(g int) int, representative func(v int) int) {
+	switch sym {
+	case "L", "F":
+		cardinality = 4
+		variant = identity
+		representative = identity
+	case "T":
+		cardinality = 2
+		variant = func(g int) int {
+			return g & 1
+		}
+		representative = identity
+	case "\\":
+		cardinality = 2
+		variant = func(g int) int {
+			return g >> 1
+		}
+		representative = func(v int) int {
+			return v << 1
+		}
+	default:
+		cardinality = 1
+		variant = func(int) int {
+			return 0
+		}
+		representative = variant
+	}
+	return cardinality, variant, representative
+}
+
+// Columns of the action table for the transforms of non square tiles,
+// none, half turn, horizontal flip and vertical flip, combining two
+// transforms xors their indices
+var kleinTransforms = [4]int{0, 2, 4, 6}
+
+// Builds the table of variant transforms [variant][action], the first
+// variant of every tile is indexed by name, quarter turns are -1 for non
+// square tiles
+func tileActions(tiles []Tile, square bool) ([][]int, map[string]int) {
+	first := make(map[string]int)
+	action := make([][]int, 0)
+
+	for _, current := range tiles {
+		T := len(action)
+		first[current.Name] = T
+
+		if !square {
+			cardinality, variant, representative := tileKleinSymmetry(current.Sym)
+			for t := 0; t < cardinality; t++ {
+				row := []int{-1, -1, -1, -1, -1, -1, -1, -1}
+				for g, k := range kleinTransforms {
+					row[k] = T + variant(representative(t)^g)
+				}
+				action = append(action, row)
+			}
+			continue
+		}
+
+		cardinality, inv1, inv2, _ := tileSymmetry(current.Sym)
+
+		for t := 0; t < cardinality; t++ {
+			action = append(action, []int{
+				T + t,
+				T + inv1(t),
+				T + inv1(inv1(t)),
+				T + inv1(inv1(inv1(t))),
+				T + inv2(t),
+				T + inv2(inv1(t)),
+				T + inv2(inv1(inv1(t))),
+				T + inv2(inv1(inv1(inv1(t)))),
+			})
+		}
+	}
+
+	return action, first
+}
+
+// Offsets of the neighbour each propagator direction constrains
+var tileOffsets = [4]Offset{{1, 0}, {0, -1}, {-1, 0}, {0, 1}}
+
+// Lists the variants (t2) allowed at tileOffsets[d] from a variant (t1)
+func tileAdjacency(propagator [][][]bool) [][][]int {
+	adjacency := make([][][]int, len(propagator))
+	for d := range propagator {
+		adjacency[d] = make([][]int, len(propagator[d]))
+		for t1 := range propagator[d] {
+			adjacency[d][t1] = make([]int, 0)
+			for t2 := range propagator[d] {
+				if propagator[d][t2][t1] {
+					adjacency[d][t1] = append(adjacency[d][t1], t2)
+				}
+			}
+		}
+	}
+	return adjacency
+}
+
+// Builds the table of which variants (t1) may neighbour a variant (t2)
+// in each direction [d][t2][t1]
+func tilePropagator(action [][]int, first map[string]int, neighbours []Neighbour, square bool) [][][]bool {
+	T := len(action)
+	propagator := make([][][]bool, 4)
+	for i := 0; i < 4; i++ {
+		propagator[i] = make([][]bool, T)
+		for t := 0; t < T; t++ {
+			propagator[i][t] = make([]bool, T)
+		}
+	}
+
+	variant := func(name string, num int) int {
+		if !square {
+			return first[name] + num
+		}
+		return action[first[name]][num]
+	}
+
+	for i := 0; i < len(neighbours); i++ {
+		neighbor := neighbours[i]
+
+		var l, r int
+		if neighbor.Vertical() {
+			u := variant(neighbor.Top, neighbor.TopNum)
+			d := variant(neighbor.Bottom, neighbor.BottomNum)
+
+			if !square {
+				propagator[1][u][d] = true
+				propagator[1][action[d][6]][action[u][6]] = true
+				propagator[1][action[u][4]][action[d][4]] = true
+				propagator[1][action[d][2]][action[u][2]] = true
+				continue
+			}
+
+			// Turn the rule into the horizontal one it is a quarter turn of
+			l, r = action[d][3], action[u][3]
+		} else {
+			l = variant(neighbor.Left, neighbor.LeftNum)
+			r = variant(neighbor.Right, neighbor.RightNum)
+		}
+
+		propagator[0][r][l] = true
+		propagator[0][action[r][6]][action[l][6]] = true
+		propagator[0][action[l][4]][action[r][4]] = true
+		propagator[0][action[l][2]][action[r][2]] = true
+
+		if !square {
+			continue
+		}
+
+		d := action[l][1]
+		u := action[r][1]
+
+		propagator[1][u][d] = true
+		propagator[1][action[d][6]][action[u][6]] = true
+		propagator[1][action[u][4]][action[d][4]] = true
+		propagator[1][action[d][2]][action[u][2]] = true
+	}
+
+	for t := 0; t < T; t++ {
+		for t2 := 0; t2 < T; t2++ {
+			propagator[2][t][t2] = propagator[0][t2][t]
+			propagator[3][t][t2] = propagator[1][t2][t]
+		}
+	}
+
+	return propagator
+}
+
+func (m *TiledModel) IterateContext(ctx context.Context, iterations int) (image.Image, bool, bool, error) {
+	finished, err := m.BaseModel.IterateContext(ctx, m, iterations)
+	return m.Render(), finished, m.IsGenSuccess(), err
+}
+
+func (m *TiledModel) GenerateContext(ctx context.Context) (image.Image, bool, error) {
+	err := m.BaseModel.GenerateContext(ctx, m)
+	return m.Render(), m.IsGenSuccess(), err
+}
+
+// Regenerates the cells inside region, in cells, keeping every other
+// cell of state as it is, see BaseModel.State
+func (m *TiledModel) Inpaint(state [][]int, region image.Rectangle) (image.Image, bool) {
+	m.BaseModel.Inpaint(m, state, region)
+	return m.Render(), m.IsGenSuccess()
+}
+
+// Regenerates the tiles of img touching rect, in pixels
+func (m *TiledModel) InpaintImage(img image.Image, rect image.Rectangle) (image.Image, bool, error) {
+	state, err := m.StateFromImage(img)
+	if err != nil {
+		return nil, false, err
+	}
+
+	region := image.Rect(
+		rect.Min.X/m.TileWidth,
+		rect.Min.Y/m.TileHeight,
+		(rect.Max.X+m.TileWidth-1)/m.TileWidth,
+		(rect.Max.Y+m.TileHeight-1)/m.TileHeight,
+	)
+
+	out, success := m.Inpaint(state, region)
+	return out, success, nil
+}
+
+// Finds the pattern of every cell of a rendered image [x][y]
+func (m *TiledModel) StateFromImage(img image.Image) ([][]int, error) {
+	bounds := img.Bounds()
+	if bounds.Dx() != m.Fmx*m.TileWidth || bounds.Dy() != m.Fmy*m.TileHeight {
+		return nil, fmt.Errorf("wfc: image is %dx%d, want %dx%d", bounds.Dx(), bounds.Dy(), m.Fmx*m.TileWidth, m.Fmy*m.TileHeight)
+	}
+
+	matches := func(t, x, y int) bool {
+		for yt := 0; yt < m.TileHeight; yt++ {
+			for xt := 0; xt < m.TileWidth; xt++ {
+				px := bounds.Min.X + x*m.TileWidth + xt
+				py := bounds.Min.Y + y*m.TileHeight + yt
+				if !sameColor(img.At(px, py), m.Tiles[t][yt*m.TileWidth+xt]) {
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	state := make([][]int, m.Fmx)
+	for x := 0; x < m.Fmx; x++ {
+		state[x] = make([]int, m.Fmy)
+		for y := 0; y < m.Fmy; y++ {
+			state[x][y] = -1
+			for t := 0; t < m.T && state[x][y] == -1; t++ {
+				if matches(t, x, y) {
+					state[x][y] = t
+				}
+			}
+			if state[x][y] == -1 {
+				return nil, fmt.Errorf("wfc: no tile matches the image at cell (%d, %d)", x, y)
+			}
+		}
+	}
+
+	return state, nil
+}
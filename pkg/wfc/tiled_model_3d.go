@@ -0,0 +1,186 @@
+package wfc
+
+import (
+	"context"
+	"fmt"
+	"image/color"
+)
+
+// Tiled model over a width x depth x height grid of voxels, z grows
+// upwards and tiles turn around the vertical axis, see Grid3D for how
+// the cells are laid out
+type TiledModel3D struct {
+	*BaseModel
+	Width      int           // Cells along x
+	Depth      int           // Cells along y
+	Height     int           // Cells along z
+	TileOf     []int         // Index into the data tiles of the tile each pattern (t) is a variant of
+	TileNames  []string      // Name of each data tile
+	Variant    []int         // Variant of its tile each pattern is, quarter turns for rotating tiles
+	Colors     []color.Color // Voxel colour of each pattern, nil for empty tiles
+	Propagator [][][]bool    // Table of which variants (t1) may neighbour a variant (t2) [d][t2][t1]
+}
+
+// Offsets of the neighbour each 3D propagator direction constrains,
+// the horizontal directions are the same as tileOffsets
+var tileOffsets3D = [6][3]int{{1, 0, 0}, {0, -1, 0}, {-1, 0, 0}, {0, 1, 0}, {0, 0, 1}, {0, 0, -1}}
+
+// Periodic only wraps the horizontal directions, the ground and the sky
+// never meet
+func NewTiledModel3D(data TiledData3D, width, depth, height int, periodic bool) *TiledModel3D {
+	m := &TiledModel3D{
+		BaseModel: &BaseModel{
+			Fmx:        width,
+			Fmy:        depth * height,
+			Periodic:   periodic,
+			Stationary: make([]float64, 0),
+		},
+		Width:  width,
+		Depth:  depth,
+		Height: height,
+	}
+
+	action, first := tileActions(data.tiles(), true)
+
+	for i, current := range data.Tiles {
+		cardinality, _, _, _ := tileSymmetry(current.Sym)
+		m.TileNames = append(m.TileNames, current.Name)
+		for t := 0; t < cardinality; t++ {
+			m.Stationary = append(m.Stationary, current.Weight)
+			m.TileOf = append(m.TileOf, i)
+			m.Variant = append(m.Variant, t)
+			m.Colors = append(m.Colors, current.Color)
+		}
+	}
+
+	m.T = len(action)
+	m.Propagator = tilePropagator3D(action, first, data.Neighbors)
+
+	m.wave = NewWave(m.Fmx*m.Fmy, m.T)
+
+	m.SetTopology(Grid3D{width, depth, height, periodic}, tileAdjacency(m.Propagator))
+
+	return m
+}
+
+// Builds the table of which variants (t1) may neighbour a variant (t2)
+// in each direction [d][t2][t1], vertical rules hold for every turn and
+// flip of both tiles together
+func tilePropagator3D(action [][]int, first map[string]int, neighbours []Neighbour) [][][]bool {
+	horizontal := make([]Neighbour, 0, len(neighbours))
+	for _, n := range neighbours {
+		if !n.Vertical() {
+			horizontal = append(horizontal, n)
+		}
+	}
+
+	T := len(action)
+	propagator := tilePropagator(action, first, horizontal, true)
+	for i := 0; i < 2; i++ {
+		layer := make([][]bool, T)
+		for t := 0; t < T; t++ {
+			layer[t] = make([]bool, T)
+		}
+		propagator = append(propagator, layer)
+	}
+
+	for _, n := range neighbours {
+		if !n.Vertical() {
+			continue
+		}
+
+		u := action[first[n.Top]][n.TopNum]
+		d := action[first[n.Bottom]][n.BottomNum]
+		for k := 0; k < 8; k++ {
+			propagator[4][action[u][k]][action[d][k]] = true
+			propagator[5][action[d][k]][action[u][k]] = true
+		}
+	}
+
+	return propagator
+}
+
+func (m *TiledModel3D) OnBoundary(x, y int) bool {
+	return false
+}
+
+// Returns every variant of a tile
+func (m *TiledModel3D) TilePatterns(tile string) ([]int, error) {
+	patterns := make([]int, 0)
+	for t := 0; t < m.T; t++ {
+		if m.TileNames[m.TileOf[t]] == tile {
+			patterns = append(patterns, t)
+		}
+	}
+	if len(patterns) == 0 {
+		return nil, fmt.Errorf("wfc: unknown tile %q", tile)
+	}
+	return patterns, nil
+}
+
+// Restricts (x, y, z) to the given tiles, kept across Clear and
+// propagated before the first observation
+func (m *TiledModel3D) Constrain(x, y, z int, tiles ...string) error {
+	if z < 0 || z >= m.Height || y < 0 || y >= m.Depth {
+		return fmt.Errorf("wfc: cell (%d, %d, %d) outside the %dx%dx%d grid", x, y, z, m.Width, m.Depth, m.Height)
+	}
+
+	patterns := make([]int, 0)
+	for _, tile := range tiles {
+		p, err := m.TilePatterns(tile)
+		if err != nil {
+			return err
+		}
+		patterns = append(patterns, p...)
+	}
+	return m.ConstrainPatterns(x, y+z*m.Depth, patterns...)
+}
+
+func (m *TiledModel3D) Propagate() bool {
+	return m.PropagateBase(m)
+}
+
+func (m *TiledModel3D) Clear() {
+	m.ClearBase(m)
+}
+
+// Puts the model back in the state of a snapshot, see Snapshot
+func (m *TiledModel3D) Restore(s *Snapshot) error {
+	return m.BaseModel.Restore(m, s)
+}
+
+// Returns the pattern of every voxel [x][y][z], -1 if undecided
+func (m *TiledModel3D) Voxels() [][][]int {
+	state := m.State()
+	voxels := make([][][]int, m.Width)
+	for x := 0; x < m.Width; x++ {
+		voxels[x] = make([][]int, m.Depth)
+		for y := 0; y < m.Depth; y++ {
+			voxels[x][y] = make([]int, m.Height)
+			for z := 0; z < m.Height; z++ {
+				voxels[x][y][z] = state[x][y+z*m.Depth]
+			}
+		}
+	}
+	return voxels
+}
+
+func (m *TiledModel3D) Iterate(iterations int) (bool, bool) {
+	finished := m.BaseModel.Iterate(m, iterations)
+	return finished, m.IsGenSuccess()
+}
+
+func (m *TiledModel3D) Generate() bool {
+	m.BaseModel.Generate(m)
+	return m.IsGenSuccess()
+}
+
+func (m *TiledModel3D) IterateContext(ctx context.Context, iterations int) (bool, bool, error) {
+	finished, err := m.BaseModel.IterateContext(ctx, m, iterations)
+	return finished, m.IsGenSuccess(), err
+}
+
+func (m *TiledModel3D) GenerateContext(ctx context.Context) (bool, error) {
+	err := m.BaseModel.GenerateContext(ctx, m)
+	return m.IsGenSuccess(), err
+}
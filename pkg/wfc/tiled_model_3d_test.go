@@ -0,0 +1,101 @@
+package wfc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+)
+
+func generateHouse(t *testing.T) *TiledModel3D {
+	data := MakeTiledData3D("../../internal/input/", "house_data.json")
+	if err := data.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	model := NewTiledModel3D(data, 8, 6, 5, false)
+	for x := 0; x < model.Width; x++ {
+		for y := 0; y < model.Depth; y++ {
+			if err := model.Constrain(x, y, 0, "ground"); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	model.SetSeed(1)
+	model.SetBacktracking(0)
+	if !model.Generate() {
+		t.Fatal("Failed to generate voxels.")
+	}
+	return model
+}
+
+func TestTiled3DNeighboursAgree(t *testing.T) {
+	model := generateHouse(t)
+	voxels := model.Voxels()
+
+	for x := 0; x < model.Width; x++ {
+		for y := 0; y < model.Depth; y++ {
+			if name := model.TileNames[model.TileOf[voxels[x][y][0]]]; name != "ground" {
+				t.Fatalf("Expected ground at (%d, %d, 0), got %s.", x, y, name)
+			}
+			for z := 0; z < model.Height; z++ {
+				t1 := voxels[x][y][z]
+				for d, o := range tileOffsets3D {
+					x2, y2, z2 := x+o[0], y+o[1], z+o[2]
+					if x2 < 0 || y2 < 0 || z2 < 0 || x2 >= model.Width || y2 >= model.Depth || z2 >= model.Height {
+						continue
+					}
+					if t2 := voxels[x2][y2][z2]; !model.Propagator[d][t2][t1] {
+						t.Fatalf("Illegal neighbour %d in direction %d of (%d, %d, %d).", t2, d, x, y, z)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestTiled3DExports(t *testing.T) {
+	model := generateHouse(t)
+
+	solid := 0
+	for _, column := range model.Voxels() {
+		for _, pile := range column {
+			for _, v := range pile {
+				if model.Colors[v] != nil {
+					solid++
+				}
+			}
+		}
+	}
+
+	var vox bytes.Buffer
+	if err := model.WriteVox(&vox); err != nil {
+		t.Fatal(err)
+	}
+	b := vox.Bytes()
+	if string(b[:4]) != "VOX " || string(b[8:12]) != "MAIN" {
+		t.Fatalf("Expected a .vox header, got %q.", b[:12])
+	}
+	// After the MAIN header and the SIZE chunk
+	xyzi := 20 + 24
+	if string(b[xyzi:xyzi+4]) != "XYZI" {
+		t.Fatal("Expected the XYZI chunk after SIZE.")
+	}
+	if count := binary.LittleEndian.Uint32(b[xyzi+12:]); int(count) != solid {
+		t.Fatalf("Expected %d voxels, got %d.", solid, count)
+	}
+
+	var out bytes.Buffer
+	if err := model.WriteJSON(&out); err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		Voxels [][][]VoxelCell `json:"voxels"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Voxels[0][0][0].Tile != "ground" || len(decoded.Voxels[7][5]) != 5 {
+		t.Fatalf("Unexpected json export %v.", decoded.Voxels[0][0])
+	}
+}
@@ -1,9 +1,12 @@
 package wfc
 
 import (
+	"context"
+	"errors"
 
 	// "fmt"
 	"image"
+	"image/color"
 	"testing"
 
 	"wfc/pkg/utils"
@@ -14,7 +17,7 @@ func simpleTiledTest(t *testing.T, dataFileName, snapshotFileName string, iterat
 	periodic := false
 	width := 20
 	height := 20
-	seed := int64(42)
+	seed := int64(3)
 	data := MakeTiledData("../../internal/input/", dataFileName)
 
 	var outputImg image.Image
@@ -55,3 +58,251 @@ func TestSimpleTiledGenerationCompletes(t *testing.T) {
 func TestSimpleTiledIterationIncomplete(t *testing.T) {
 	simpleTiledTest(t, "castle_data.json", "castle_incomplete.png", 5)
 }
+
+func TestSimpleTiledBacktrackingRecovers(t *testing.T) {
+	data := MakeTiledData("../../internal/input/", "castle_data.json")
+	seed := int64(1) // Runs into a contradiction without backtracking
+
+	model := NewTiledModel(data, 20, 20, false)
+	model.SetSeed(seed)
+	if _, success := model.Generate(); success {
+		t.Fatal("Expected a contradiction without backtracking.")
+	}
+
+	model = NewTiledModel(data, 20, 20, false)
+	model.SetSeed(seed)
+	model.SetBacktracking(0)
+	if _, success := model.Generate(); !success {
+		t.Fatal("Failed to generate image with backtracking.")
+	}
+	if model.Backtracks == 0 {
+		t.Fatal("Expected at least one backtrack.")
+	}
+}
+
+func TestSimpleTiledGenerateContextCancelled(t *testing.T) {
+	data := MakeTiledData("../../internal/input/", "castle_data.json")
+	model := NewTiledModel(data, 20, 20, false)
+	model.SetSeed(3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	outputImg, success, err := model.GenerateContext(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v.", err)
+	}
+	if success {
+		t.Fatal("Cancelled generation reported success.")
+	}
+	if outputImg.Bounds().Dx() != 20*data.TileWidth {
+		t.Fatal("Expected a renderable partial image.")
+	}
+
+	// The model can still be iterated to completion
+	if _, finished, success := model.Iterate(1 << 20); !finished || !success {
+		t.Fatal("Failed to finish generation after cancellation.")
+	}
+}
+
+func TestSimpleTiledConstrainSurvivesClear(t *testing.T) {
+	data := MakeTiledData("../../internal/input/", "castle_data.json")
+	model := NewTiledModel(data, 20, 20, false)
+	model.SetSeed(1)
+	model.SetBacktracking(0)
+
+	if err := model.Constrain(0, 0, "tower"); err != nil {
+		t.Fatal(err)
+	}
+	if err := model.Constrain(10, 10, "road 1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := model.Constrain(0, 0, "moat"); err == nil {
+		t.Fatal("Expected an error for an unknown tile.")
+	}
+
+	road, _ := model.TilePatterns("road 1")
+
+	for i := 0; i < 2; i++ {
+		if _, success := model.Generate(); !success {
+			t.Fatal("Failed to generate image.")
+		}
+
+		corner := model.wave.Cell(0).First()
+		if model.TileNames[model.TileOf[corner]] != "tower" {
+			t.Fatalf("Expected a tower at (0, 0), got %s.", model.TileNames[model.TileOf[corner]])
+		}
+		if !model.Allowed(10, 10, road[0]) || model.Remaining(10, 10) != 1 {
+			t.Fatal("Expected road variant 1 at (10, 10).")
+		}
+	}
+}
+
+func TestSimpleTiledBorderClosesEdges(t *testing.T) {
+	for _, rule := range []Neighbour{{Left: "ground", Right: BorderTile}, {Top: BorderTile, Bottom: "ground"}} {
+		testBorderClosesEdges(t, rule)
+	}
+}
+
+func testBorderClosesEdges(t *testing.T, rule Neighbour) {
+	data := MakeTiledData("../../internal/input/", "castle_data.json")
+	data.Neighbors = append(data.Neighbors, rule)
+	if err := data.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	model := NewTiledModel(data, 20, 20, false)
+	model.SetSeed(1)
+	model.SetBacktracking(0)
+	if _, success := model.Generate(); !success {
+		t.Fatal("Failed to generate image.")
+	}
+
+	for y := 0; y < model.Fmy; y++ {
+		for x := 0; x < model.Fmx; x++ {
+			if x != 0 && y != 0 && x != model.Fmx-1 && y != model.Fmy-1 {
+				continue
+			}
+			name := model.TileNames[model.TileOf[model.wave.Cell(x+y*model.Fmx).First()]]
+			if name != "ground" {
+				t.Fatalf("Expected ground on the edge at (%d, %d) with %+v, got %s.", x, y, rule, name)
+			}
+		}
+	}
+}
+
+func TestNonSquareTilesFlipInsteadOfRotating(t *testing.T) {
+	green := color.RGBA{0, 255, 0, 255}
+	white := color.RGBA{255, 255, 255, 255}
+
+	a := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	b := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 4; x++ {
+			a.Set(x, y, white)
+			b.Set(x, y, white)
+		}
+	}
+	b.Set(0, 0, green)
+
+	data := TiledData{
+		TileWidth:  4,
+		TileHeight: 2,
+		Tiles: []Tile{
+			{Name: "a", Sym: "X", Weight: 1, Variants: []image.Image{a}},
+			{Name: "b", Sym: "L", Weight: 1, Variants: []image.Image{b}},
+		},
+		Neighbors: []Neighbour{
+			{Left: "a", Right: "a"},
+			{Top: "a", Bottom: "a"},
+			{Top: "a", Bottom: "b"},
+			{Top: "b", Bottom: "a"},
+		},
+	}
+	for k := 0; k < 4; k++ {
+		data.Neighbors = append(data.Neighbors,
+			Neighbour{Left: "a", Right: "b", RightNum: k},
+			Neighbour{Left: "b", LeftNum: k, Right: "a"},
+		)
+	}
+	if err := data.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	model := NewTiledModel(data, 10, 6, false)
+	if model.T != 5 {
+		t.Fatalf("Expected 5 patterns, got %d.", model.T)
+	}
+
+	// Half turn, horizontal flip and vertical flip of b
+	for v, corner := range []image.Point{{0, 0}, {3, 1}, {3, 0}, {0, 1}} {
+		if !sameColor(model.Tiles[1+v][corner.X+corner.Y*4], green) {
+			t.Errorf("Expected variant %d of b to be green at %v.", v, corner)
+		}
+	}
+
+	model.SetSeed(1)
+	outputImg, success := model.Generate()
+	if !success {
+		t.Fatal("Failed to generate image.")
+	}
+	if size := outputImg.Bounds().Size(); size != image.Pt(40, 12) {
+		t.Fatalf("Expected a 40x12 image, got %v.", size)
+	}
+
+	state := model.State()
+	for x := 0; x < model.Fmx; x++ {
+		for y := 0; y < model.Fmy; y++ {
+			if x > 0 && !model.Propagator[0][state[x][y]][state[x-1][y]] {
+				t.Fatalf("Illegal neighbours left of (%d, %d).", x, y)
+			}
+			if y > 0 && !model.Propagator[1][state[x][y-1]][state[x][y]] {
+				t.Fatalf("Illegal neighbours above (%d, %d).", x, y)
+			}
+		}
+	}
+}
+
+func TestFSymmetryVariantsMatchActions(t *testing.T) {
+	green := color.RGBA{0, 255, 0, 255}
+	white := color.RGBA{255, 255, 255, 255}
+
+	img := image.NewRGBA(image.Rect(0, 0, 3, 3))
+	for y := 0; y < 3; y++ {
+		for x := 0; x < 3; x++ {
+			img.Set(x, y, white)
+		}
+	}
+	img.Set(0, 0, green)
+	img.Set(1, 0, green)
+
+	tiles := []Tile{{Name: "f", Sym: "F", Weight: 1, Variants: []image.Image{img}}}
+	model := NewTiledModel(TiledData{TileWidth: 3, TileHeight: 3, Tiles: tiles}, 4, 4, false)
+	if model.T != 8 {
+		t.Fatalf("Expected 8 patterns, got %d.", model.T)
+	}
+
+	turn := func(p TilePattern) TilePattern {
+		q := make(TilePattern, len(p))
+		for y := 0; y < 3; y++ {
+			for x := 0; x < 3; x++ {
+				q[x+y*3] = p[2-y+x*3]
+			}
+		}
+		return q
+	}
+	mirror := func(p TilePattern) TilePattern {
+		q := make(TilePattern, len(p))
+		for y := 0; y < 3; y++ {
+			for x := 0; x < 3; x++ {
+				q[x+y*3] = p[2-x+y*3]
+			}
+		}
+		return q
+	}
+	same := func(p, q TilePattern) bool {
+		for i := range p {
+			if !sameColor(p[i], q[i]) {
+				return false
+			}
+		}
+		return true
+	}
+
+	// Every variant is distinct, and the actions name the variant
+	// drawn by turning or mirroring it
+	action, _ := tileActions(tiles, true)
+	for v := 0; v < 8; v++ {
+		for w := 0; w < v; w++ {
+			if same(model.Tiles[v], model.Tiles[w]) {
+				t.Fatalf("Variants %d and %d are the same.", w, v)
+			}
+		}
+		if !same(turn(model.Tiles[v]), model.Tiles[action[v][1]]) {
+			t.Errorf("Turning variant %d does not draw variant %d.", v, action[v][1])
+		}
+		if !same(mirror(model.Tiles[v]), model.Tiles[action[v][4]]) {
+			t.Errorf("Mirroring variant %d does not draw variant %d.", v, action[v][4])
+		}
+	}
+}
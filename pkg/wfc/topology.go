@@ -0,0 +1,106 @@
+package wfc
+
+// How the cells of a wave connect, Adjacency is indexed by the
+// directions of the topology
+type Topology interface {
+	Directions() int                        // Number of neighbours of a cell
+	Neighbour(x, y, d int) (int, int, bool) // Cell in direction d from (x, y), ok is false off the edge
+}
+
+// Flat grid where the neighbour in direction d is Offsets[d] away
+type Grid struct {
+	Width    int      // Cells along x
+	Height   int      // Cells along y
+	Periodic bool     // Wraps around the edges?
+	Offsets  []Offset // Position of the neighbour in each direction
+}
+
+func (g Grid) Directions() int {
+	return len(g.Offsets)
+}
+
+func (g Grid) Neighbour(x, y, d int) (int, int, bool) {
+	x2 := x + g.Offsets[d].X
+	y2 := y + g.Offsets[d].Y
+
+	if x2 < 0 || x2 >= g.Width || y2 < 0 || y2 >= g.Height {
+		if !g.Periodic {
+			return x2, y2, false
+		}
+		x2 = (x2 + g.Width) % g.Width
+		y2 = (y2 + g.Height) % g.Height
+	}
+
+	return x2, y2, true
+}
+
+// Width x Depth x Height grid laid out as Height layers of Width x
+// Depth cells, (x, y, z) is the cell (x, y+z*Depth), directions follow
+// tileOffsets3D and only the horizontal ones wrap
+type Grid3D struct {
+	Width    int  // Cells along x
+	Depth    int  // Cells along y
+	Height   int  // Cells along z
+	Periodic bool // Wraps around the horizontal edges?
+}
+
+func (g Grid3D) Directions() int {
+	return len(tileOffsets3D)
+}
+
+func (g Grid3D) Neighbour(x, y, d int) (int, int, bool) {
+	o := tileOffsets3D[d]
+	x2, y2, z2 := x+o[0], y%g.Depth+o[1], y/g.Depth+o[2]
+
+	if z2 < 0 || z2 >= g.Height {
+		return x2, y2, false
+	}
+	if x2 < 0 || x2 >= g.Width || y2 < 0 || y2 >= g.Depth {
+		if !g.Periodic {
+			return x2, y2, false
+		}
+		x2 = (x2 + g.Width) % g.Width
+		y2 = (y2 + g.Depth) % g.Depth
+	}
+
+	return x2, y2 + z2*g.Depth, true
+}
+
+// Grid of pointy topped hexagons with odd rows pushed half a cell right,
+// directions go anticlockwise from east: east, north east, north west,
+// west, south west, south east. Rows only wrap for an even Height
+type HexGrid struct {
+	Width    int  // Cells along a row
+	Height   int  // Rows
+	Periodic bool // Wraps around the edges?
+}
+
+// Neighbour offsets of even and odd rows in each direction
+var hexOffsets = [2][6]Offset{
+	{{1, 0}, {0, -1}, {-1, -1}, {-1, 0}, {-1, 1}, {0, 1}},
+	{{1, 0}, {1, -1}, {0, -1}, {-1, 0}, {0, 1}, {1, 1}},
+}
+
+func (g HexGrid) Directions() int {
+	return 6
+}
+
+func (g HexGrid) Neighbour(x, y, d int) (int, int, bool) {
+	o := hexOffsets[y&1][d]
+	x2, y2 := x+o.X, y+o.Y
+
+	if y2 < 0 || y2 >= g.Height {
+		if !g.Periodic || g.Height%2 != 0 {
+			return x2, y2, false
+		}
+		y2 = (y2 + g.Height) % g.Height
+	}
+	if x2 < 0 || x2 >= g.Width {
+		if !g.Periodic {
+			return x2, y2, false
+		}
+		x2 = (x2 + g.Width) % g.Width
+	}
+
+	return x2, y2, true
+}
@@ -0,0 +1,471 @@
+package wfc
+
+import (
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"wfc/pkg/utils"
+)
+
+// A TiledData read from a Tiled (mapeditor.org) .tsx tileset, see
+// LoadTSXData
+type TSXData struct {
+	TiledData
+	TileIDs []int // Tiled id of each data tile
+}
+
+type tsxTileset struct {
+	TileWidth  int          `xml:"tilewidth,attr"`
+	TileHeight int          `xml:"tileheight,attr"`
+	Spacing    int          `xml:"spacing,attr"`
+	Margin     int          `xml:"margin,attr"`
+	Columns    int          `xml:"columns,attr"`
+	Image      *tsxImage    `xml:"image"`            // Image of every tile, nil for a collection of images
+	Tiles      []tsxTile    `xml:"tile"`             //
+	WangSets   []tsxWangSet `xml:"wangsets>wangset"` //
+}
+
+type tsxImage struct {
+	Source string `xml:"source,attr"`
+}
+
+type tsxTile struct {
+	ID         int           `xml:"id,attr"`
+	Type       string        `xml:"type,attr"`
+	Class      string        `xml:"class,attr"`
+	Image      *tsxImage     `xml:"image"`
+	Properties []tsxProperty `xml:"properties>property"`
+}
+
+type tsxProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+	Text  string `xml:",chardata"` // Value of multi line strings
+}
+
+type tsxWangSet struct {
+	Tiles []tsxWangTile `xml:"wangtile"`
+}
+
+type tsxWangTile struct {
+	TileID int    `xml:"tileid,attr"`
+	WangID string `xml:"wangid,attr"`
+}
+
+// Same as LoadTSXData but panics on error
+func MakeTSXData(file string) TSXData {
+	data, err := LoadTSXData(file)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// Reads a Tiled tileset and its images. Tiles with a wfc.* property or
+// in a wang set are used, the rest are skipped:
+//
+//	wfc.name       name of the tile, defaults to its class then "tile<id>"
+//	wfc.symmetry   symmetry as in the json data, defaults to X
+//	wfc.weight     defaults to 1
+//	wfc.right      comma separated "name num" tiles allowed to the right
+//	               of variant 0, wfc.right.1 for variant 1 and so on
+//	wfc.bottom     same for tiles below, only needed for non square tiles
+//
+// Tiles of a wang set may also touch wherever their sides have the same
+// colours, in every variant. Errors reading files are a *FileError or
+// *TileError
+func LoadTSXData(file string) (TSXData, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return TSXData{}, &FileError{Path: file, Err: err}
+	}
+
+	bad := func(field string, err error) error {
+		return fmt.Errorf("wfc: parsing %s: %s: %w", file, field, err)
+	}
+
+	var ts tsxTileset
+	if err := xml.Unmarshal(content, &ts); err != nil {
+		return TSXData{}, fmt.Errorf("wfc: parsing %s: %w", file, err)
+	}
+
+	dir := filepath.Dir(file)
+	var sheet image.Image
+	if ts.Image != nil {
+		path := filepath.Join(dir, ts.Image.Source)
+		if sheet, err = utils.LoadImage(path); err != nil {
+			return TSXData{}, &TileError{Path: path, Err: err}
+		}
+	}
+
+	// Tiles in use by id
+	tiles := make(map[int]*tsxTile)
+	for i := range ts.Tiles {
+		for _, p := range ts.Tiles[i].Properties {
+			if strings.HasPrefix(p.Name, "wfc.") {
+				tiles[ts.Tiles[i].ID] = &ts.Tiles[i]
+			}
+		}
+	}
+	for _, set := range ts.WangSets {
+		for _, wt := range set.Tiles {
+			if tiles[wt.TileID] == nil {
+				tiles[wt.TileID] = &tsxTile{ID: wt.TileID}
+				for i := range ts.Tiles {
+					if ts.Tiles[i].ID == wt.TileID {
+						tiles[wt.TileID] = &ts.Tiles[i]
+					}
+				}
+			}
+		}
+	}
+
+	data := TSXData{
+		TiledData: TiledData{
+			TileWidth:  ts.TileWidth,
+			TileHeight: ts.TileHeight,
+		},
+	}
+	for id := range tiles {
+		data.TileIDs = append(data.TileIDs, id)
+	}
+	sort.Ints(data.TileIDs)
+
+	names := make(map[int]string)
+	for _, id := range data.TileIDs {
+		tt := tiles[id]
+		tile := Tile{Name: tt.Class, Sym: "X", Weight: 1}
+		if tile.Name == "" {
+			tile.Name = tt.Type
+		}
+		if tile.Name == "" {
+			tile.Name = "tile" + strconv.Itoa(id)
+		}
+
+		rules := make(map[string]string)
+		for _, p := range tt.Properties {
+			value := p.Value
+			if value == "" {
+				value = p.Text
+			}
+			switch p.Name {
+			case "wfc.name":
+				tile.Name = value
+			case "wfc.symmetry":
+				tile.Sym = value
+			case "wfc.weight":
+				if tile.Weight, err = strconv.ParseFloat(value, 64); err != nil {
+					return TSXData{}, bad(fmt.Sprintf("tile %d: wfc.weight", id), err)
+				}
+			default:
+				if strings.HasPrefix(p.Name, "wfc.right") || strings.HasPrefix(p.Name, "wfc.bottom") {
+					rules[p.Name] = value
+				}
+			}
+		}
+		names[id] = tile.Name
+
+		img, err := tsxTileImage(ts, tt, sheet, dir)
+		if err != nil {
+			return TSXData{}, &TileError{Tile: tile.Name, Path: file, Err: err}
+		}
+		tile.Variants = []image.Image{img}
+		data.Tiles = append(data.Tiles, tile)
+
+		properties := make([]string, 0, len(rules))
+		for property := range rules {
+			properties = append(properties, property)
+		}
+		sort.Strings(properties)
+
+		for _, property := range properties {
+			value := rules[property]
+			rule, num, _ := strings.Cut(strings.TrimPrefix(property, "wfc."), ".")
+			n := 0
+			if num != "" {
+				if n, err = strconv.Atoi(num); err != nil {
+					return TSXData{}, bad(fmt.Sprintf("tile %d: %s", id, property), err)
+				}
+			}
+			if rule != "right" && rule != "bottom" {
+				return TSXData{}, bad(fmt.Sprintf("tile %d: %s", id, property), fmt.Errorf("want wfc.right or wfc.bottom"))
+			}
+
+			for _, entry := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == '\n' }) {
+				fields := strings.Fields(entry)
+				if len(fields) == 0 {
+					continue
+				}
+				m := 0
+				if len(fields) > 2 {
+					err = fmt.Errorf("want name or name num, got %q", entry)
+				} else if len(fields) == 2 {
+					m, err = strconv.Atoi(fields[1])
+				}
+				if err != nil {
+					return TSXData{}, bad(fmt.Sprintf("tile %d: %s", id, property), err)
+				}
+
+				if rule == "right" {
+					data.Neighbors = append(data.Neighbors, Neighbour{Left: tile.Name, LeftNum: n, Right: fields[0], RightNum: m})
+				} else {
+					data.Neighbors = append(data.Neighbors, Neighbour{Top: tile.Name, TopNum: n, Bottom: fields[0], BottomNum: m})
+				}
+			}
+		}
+	}
+
+	for _, set := range ts.WangSets {
+		neighbours, err := wangNeighbours(set, names, data.Tiles, data.TileWidth == data.TileHeight)
+		if err != nil {
+			return TSXData{}, bad("wangset", err)
+		}
+		data.Neighbors = append(data.Neighbors, neighbours...)
+	}
+
+	data.TiledData = data.TiledData.sized()
+	return data, nil
+}
+
+// Cuts a tile out of the tileset image, or loads its own image
+func tsxTileImage(ts tsxTileset, tt *tsxTile, sheet image.Image, dir string) (image.Image, error) {
+	if tt.Image != nil {
+		return utils.LoadImage(filepath.Join(dir, tt.Image.Source))
+	}
+	if sheet == nil || ts.Columns < 1 {
+		return nil, fmt.Errorf("tile %d has no image", tt.ID)
+	}
+
+	x := ts.Margin + (tt.ID%ts.Columns)*(ts.TileWidth+ts.Spacing)
+	y := ts.Margin + (tt.ID/ts.Columns)*(ts.TileHeight+ts.Spacing)
+	r := image.Rect(x, y, x+ts.TileWidth, y+ts.TileHeight).Add(sheet.Bounds().Min)
+	if !r.In(sheet.Bounds()) {
+		return nil, fmt.Errorf("tile %d is outside the tileset image", tt.ID)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, ts.TileWidth, ts.TileHeight))
+	draw.Draw(img, img.Bounds(), sheet, r.Min, draw.Src)
+	return img, nil
+}
+
+// Lists the left and right variants whose touching sides have the same
+// wang colours. A wang id holds the colours of the top, top right, right
+// and so on clockwise, a quarter turn anticlockwise moves each colour
+// two places back and flips mirror them
+func wangNeighbours(set tsxWangSet, names map[int]string, tiles []Tile, square bool) ([]Neighbour, error) {
+	sym := make(map[string]string)
+	for _, tile := range tiles {
+		sym[tile.Name] = tile.Sym
+	}
+
+	type variant struct {
+		name string
+		num  int
+		id   [8]int
+	}
+	variants := make([]variant, 0)
+	for _, wt := range set.Tiles {
+		var id [8]int
+		fields := strings.Split(wt.WangID, ",")
+		if len(fields) != 8 {
+			return nil, fmt.Errorf("tile %d: want 8 comma separated wang colours, got %q", wt.TileID, wt.WangID)
+		}
+		for i, f := range fields {
+			c, err := strconv.Atoi(strings.TrimSpace(f))
+			if err != nil {
+				return nil, fmt.Errorf("tile %d: %v", wt.TileID, err)
+			}
+			id[i] = c
+		}
+
+		name := names[wt.TileID]
+		if square {
+			// Variants past the fourth are mirrored turns
+			var turns [4][8]int
+			turns[0] = id
+			for k := 1; k < 4; k++ {
+				for i := range turns[k] {
+					turns[k][i] = turns[k-1][(i+2)%8]
+				}
+			}
+
+			cardinality, _, _, _ := tileSymmetry(sym[name])
+			for k := 0; k < cardinality; k++ {
+				if k < 4 {
+					variants = append(variants, variant{name, k, turns[k]})
+					continue
+				}
+				var mirrored [8]int
+				for i := range mirrored {
+					mirrored[i] = turns[k-4][(8-i)%8]
+				}
+				variants = append(variants, variant{name, k, mirrored})
+			}
+			continue
+		}
+
+		cardinality, _, representative := tileKleinSymmetry(sym[name])
+		for k := 0; k < cardinality; k++ {
+			g := representative(k)
+			flipped := id
+			for i := range flipped {
+				j := i
+				if g == 1 || g == 2 {
+					j = (8 - j) % 8 // Mirror left to right
+				}
+				if g == 1 || g == 3 {
+					j = (12 - j) % 8 // Mirror top to bottom
+				}
+				flipped[i] = id[j]
+			}
+			variants = append(variants, variant{name, k, flipped})
+		}
+	}
+
+	// Right side is top right, right, bottom right, the left side it
+	// touches is top left, left, bottom left
+	neighbours := make([]Neighbour, 0)
+	for _, a := range variants {
+		for _, b := range variants {
+			if a.id[1] == b.id[7] && a.id[2] == b.id[6] && a.id[3] == b.id[5] {
+				neighbours = append(neighbours, Neighbour{Left: a.name, LeftNum: a.num, Right: b.name, RightNum: b.num})
+			}
+			if !square && a.id[5] == b.id[7] && a.id[4] == b.id[0] && a.id[3] == b.id[1] {
+				neighbours = append(neighbours, Neighbour{Top: a.name, TopNum: a.num, Bottom: b.name, BottomNum: b.num})
+			}
+		}
+	}
+	return neighbours, nil
+}
+
+// Tiled flip flags of a gid
+const (
+	tmxFlipH = 0x80000000
+	tmxFlipV = 0x40000000
+	tmxFlipD = 0x20000000 // Swaps x and y, applied before the other flips
+)
+
+// Flags turning a square tile anticlockwise by quarter turns, then
+// mirroring it for variants past the fourth
+var tmxTurns = [8]uint32{
+	0, tmxFlipD | tmxFlipV, tmxFlipH | tmxFlipV, tmxFlipD | tmxFlipH,
+	tmxFlipH, tmxFlipD | tmxFlipH | tmxFlipV, tmxFlipV, tmxFlipD,
+}
+
+type tmxMap struct {
+	XMLName      xml.Name   `xml:"map"`
+	Version      string     `xml:"version,attr"`
+	Orientation  string     `xml:"orientation,attr"`
+	RenderOrder  string     `xml:"renderorder,attr"`
+	Width        int        `xml:"width,attr"`
+	Height       int        `xml:"height,attr"`
+	TileWidth    int        `xml:"tilewidth,attr"`
+	TileHeight   int        `xml:"tileheight,attr"`
+	Infinite     int        `xml:"infinite,attr"`
+	NextLayerID  int        `xml:"nextlayerid,attr"`
+	NextObjectID int        `xml:"nextobjectid,attr"`
+	Tileset      tmxTileset `xml:"tileset"`
+	Layer        tmxLayer   `xml:"layer"`
+}
+
+type tmxTileset struct {
+	FirstGID int    `xml:"firstgid,attr"`
+	Source   string `xml:"source,attr"`
+}
+
+type tmxLayer struct {
+	ID     int     `xml:"id,attr"`
+	Name   string  `xml:"name,attr"`
+	Width  int     `xml:"width,attr"`
+	Height int     `xml:"height,attr"`
+	Data   tmxData `xml:"data"`
+}
+
+type tmxData struct {
+	Encoding string `xml:"encoding,attr"`
+	CSV      string `xml:",innerxml"` // Only digits and commas, written as is to keep its line breaks
+}
+
+// Writes the output as a Tiled map of one layer using the tileset at
+// source, relative to where the map is saved. The model must be built
+// from tileset, variants are drawn with flip flags and undecided cells
+// are left empty
+func (m *TiledModel) WriteTMX(w io.Writer, tileset TSXData, source string) error {
+	if len(tileset.TileIDs) != len(m.TileNames) {
+		return fmt.Errorf("wfc: tileset has %d tiles, model has %d", len(tileset.TileIDs), len(m.TileNames))
+	}
+
+	square := m.TileWidth == m.TileHeight
+	gid := func(t int) uint32 {
+		id := uint32(tileset.TileIDs[m.TileOf[t]] + 1)
+		if square {
+			return id | tmxTurns[m.Variant[t]]
+		}
+
+		_, _, representative := tileKleinSymmetry(tileset.Tiles[m.TileOf[t]].Sym)
+		switch representative(m.Variant[t]) {
+		case 1:
+			return id | tmxFlipH | tmxFlipV
+		case 2:
+			return id | tmxFlipH
+		case 3:
+			return id | tmxFlipV
+		}
+		return id
+	}
+
+	var csv strings.Builder
+	csv.WriteString("\n")
+	state := m.State()
+	for y := 0; y < m.Fmy; y++ {
+		for x := 0; x < m.Fmx; x++ {
+			if t := state[x][y]; t != -1 {
+				csv.WriteString(strconv.FormatUint(uint64(gid(t)), 10))
+			} else {
+				csv.WriteString("0")
+			}
+			if x < m.Fmx-1 || y < m.Fmy-1 {
+				csv.WriteString(",")
+			}
+		}
+		csv.WriteString("\n")
+	}
+
+	out := tmxMap{
+		Version:      "1.10",
+		Orientation:  "orthogonal",
+		RenderOrder:  "right-down",
+		Width:        m.Fmx,
+		Height:       m.Fmy,
+		TileWidth:    m.TileWidth,
+		TileHeight:   m.TileHeight,
+		NextLayerID:  2,
+		NextObjectID: 1,
+		Tileset:      tmxTileset{FirstGID: 1, Source: source},
+		Layer: tmxLayer{
+			ID:     1,
+			Name:   "wfc",
+			Width:  m.Fmx,
+			Height: m.Fmy,
+			Data:   tmxData{Encoding: "csv", CSV: csv.String()},
+		},
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", " ")
+	if err := enc.Encode(out); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
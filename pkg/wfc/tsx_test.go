@@ -0,0 +1,171 @@
+package wfc
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestTSXMatchesJSONData(t *testing.T) {
+	tsx := MakeTSXData("../../internal/input/castle.tsx")
+	if err := tsx.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	data := MakeTiledData("../../internal/input/", "castle_data.json")
+
+	fromTSX := NewTiledModel(tsx.TiledData, 5, 5, false)
+	fromJSON := NewTiledModel(data, 5, 5, false)
+	if !reflect.DeepEqual(fromTSX.TileNames, fromJSON.TileNames) {
+		t.Fatalf("Tile names %v, want %v.", fromTSX.TileNames, fromJSON.TileNames)
+	}
+	if !reflect.DeepEqual(fromTSX.Propagator, fromJSON.Propagator) {
+		t.Fatal("Propagator differs from the json data.")
+	}
+}
+
+// Grass, water and a shore with grass on top, in a 3 tile sheet
+const wangTSX = `<?xml version="1.0" encoding="UTF-8"?>
+<tileset version="1.10" name="shore" tilewidth="4" tileheight="4" tilecount="3" columns="3">
+ <image source="shore.png" width="12" height="4"/>
+ <tile id="2">
+  <properties>
+   <property name="wfc.name" value="shore"/>
+   <property name="wfc.symmetry" value="T"/>
+  </properties>
+ </tile>
+ <wangsets>
+  <wangset name="terrain" type="edge" tile="-1">
+   <wangcolor name="grass" color="#00ff00" tile="-1" probability="1"/>
+   <wangcolor name="water" color="#0000ff" tile="-1" probability="1"/>
+   <wangcolor name="coast" color="#ffff00" tile="-1" probability="1"/>
+   <wangtile tileid="0" wangid="1,0,1,0,1,0,1,0"/>
+   <wangtile tileid="1" wangid="2,0,2,0,2,0,2,0"/>
+   <wangtile tileid="2" wangid="1,0,3,0,2,0,3,0"/>
+  </wangset>
+ </wangsets>
+</tileset>
+`
+
+// Applies Tiled flip flags to a wang id, the diagonal flip first
+func flipWangID(id [8]int, gid uint32) [8]int {
+	apply := func(f func(i int) int) {
+		var out [8]int
+		for i := range out {
+			out[i] = id[f(i)]
+		}
+		id = out
+	}
+	if gid&tmxFlipD != 0 {
+		apply(func(i int) int { return (14 - i) % 8 })
+	}
+	if gid&tmxFlipH != 0 {
+		apply(func(i int) int { return (8 - i) % 8 })
+	}
+	if gid&tmxFlipV != 0 {
+		apply(func(i int) int { return (12 - i) % 8 })
+	}
+	return id
+}
+
+func TestTSXWangSetsAndTMXExport(t *testing.T) {
+	dir := t.TempDir()
+	sheet := image.NewRGBA(image.Rect(0, 0, 12, 4))
+	for x := 0; x < 12; x++ {
+		for y := 0; y < 4; y++ {
+			c := color.RGBA{0, 255, 0, 255}
+			if x >= 4 && (x < 8 || y >= 2) {
+				c = color.RGBA{0, 0, 255, 255}
+			}
+			sheet.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, sheet); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "shore.png"), buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "shore.tsx"), []byte(wangTSX), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tsx, err := LoadTSXData(filepath.Join(dir, "shore.tsx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tsx.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	model := NewTiledModel(tsx.TiledData, 8, 8, false)
+	model.SetSeed(4)
+	model.SetBacktracking(0)
+	img, success := model.Generate()
+	if !success {
+		t.Fatal("Failed to generate image with backtracking.")
+	}
+
+	buf.Reset()
+	if err := model.WriteTMX(&buf, tsx, "shore.tsx"); err != nil {
+		t.Fatal(err)
+	}
+	var m tmxMap
+	if err := xml.Unmarshal(buf.Bytes(), &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Tileset.Source != "shore.tsx" || m.Width != 8 || m.Height != 8 {
+		t.Fatalf("Unexpected map header %+v.", m)
+	}
+
+	wangIDs := [3][8]int{{1, 0, 1, 0, 1, 0, 1, 0}, {2, 0, 2, 0, 2, 0, 2, 0}, {1, 0, 3, 0, 2, 0, 3, 0}}
+	gids := strings.Split(strings.TrimSpace(m.Layer.Data.CSV), ",")
+	ids := make([][8]int, len(gids))
+	for i, s := range gids {
+		gid, err := strconv.ParseUint(strings.TrimSpace(s), 10, 32)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[i] = flipWangID(wangIDs[gid&0x0fffffff-1], uint32(gid))
+
+		// The flags must draw the tile the way the model does
+		x, y := i%8, i/8
+		tile := sheet.SubImage(image.Rect(0, 0, 4, 4).Add(image.Pt(4*int(gid&0x0fffffff-1), 0)))
+		for py := 0; py < 4; py++ {
+			for px := 0; px < 4; px++ {
+				sx, sy := px, py
+				if gid&tmxFlipH != 0 {
+					sx = 3 - sx
+				}
+				if gid&tmxFlipV != 0 {
+					sy = 3 - sy
+				}
+				if gid&tmxFlipD != 0 {
+					sx, sy = sy, sx
+				}
+				want := tile.At(tile.Bounds().Min.X+sx, sy)
+				if !sameColor(img.At(4*x+px, 4*y+py), want) {
+					t.Fatalf("Gid %d at (%d, %d) draws differently from the output.", gid, x, y)
+				}
+			}
+		}
+	}
+
+	for i := range ids {
+		x, y := i%8, i/8
+		if x < 7 && ids[i][2] != ids[i+1][6] {
+			t.Fatalf("Wang colours clash between (%d, %d) and its right neighbour.", x, y)
+		}
+		if y < 7 && ids[i][4] != ids[i+8][0] {
+			t.Fatalf("Wang colours clash between (%d, %d) and its bottom neighbour.", x, y)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package wfc
+
+import "fmt"
+
+// Collects the problems found by the Validate methods of the data types
+type validator struct {
+	issues        []ValidationIssue
+	cardinalities map[string]int // Number of variants of each tile by name
+}
+
+func newValidator() *validator {
+	return &validator{
+		issues:        make([]ValidationIssue, 0),
+		cardinalities: make(map[string]int),
+	}
+}
+
+func (v *validator) report(tile, field, format string, args ...any) {
+	v.issues = append(v.issues, ValidationIssue{
+		Tile:    tile,
+		Field:   field,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// Checks the size of the tile images
+func (v *validator) size(width, height int) {
+	if width < 1 {
+		v.report("", "tileWidth", "must be positive, got %d", width)
+	}
+	if height < 1 {
+		v.report("", "tileHeight", "must be positive, got %d", height)
+	}
+}
+
+// Checks the name and weight of the tile at field and records its number
+// of variants, symmetries lists the valid symmetries when ok is false
+func (v *validator) tile(field, name string, weight float64, sym string, cardinality int, ok bool, symmetries string) {
+	if name == "" {
+		v.report(name, field+".name", "missing")
+	} else if _, dup := v.cardinalities[name]; dup {
+		v.report(name, field+".name", "duplicate tile %q", name)
+	}
+
+	if !ok {
+		v.report(name, field+".symmetry", "unknown symmetry %q, want one of %s", sym, symmetries)
+	}
+	v.cardinalities[name] = cardinality
+
+	if weight <= 0 {
+		v.report(name, field+".weight", "must be positive, got %g", weight)
+	}
+}
+
+// Checks the tile has an image per variant in unique mode, or one
+// otherwise, each of the given size
+func (v *validator) images(field string, tile Tile, unique bool, width, height int) {
+	need := 1
+	if unique {
+		need = v.cardinalities[tile.Name]
+	}
+	if len(tile.Variants) < need {
+		v.report(tile.Name, field, "has %d image(s), symmetry %q needs %d", len(tile.Variants), tile.Sym, need)
+	}
+
+	for j, img := range tile.Variants {
+		size := img.Bounds().Size()
+		if width > 0 && height > 0 && (size.X != width || size.Y != height) {
+			v.report(tile.Name, fmt.Sprintf("%s.variants[%d]", field, j), "image is %dx%d, want %dx%d", size.X, size.Y, width, height)
+		}
+	}
+}
+
+// Checks a rule names a known tile and a num the model can look up. The
+// num indexes the tile's row of the action table when it has actions
+// entries, so it may name a variant by one of its transforms, otherwise
+// actions is 0 and it is the variant itself
+func (v *validator) side(field, name string, num, actions int) bool {
+	cardinality, ok := v.cardinalities[name]
+	if !ok {
+		v.report(name, field, "unknown tile %q", name)
+		return false
+	}
+	if actions == 0 {
+		actions = cardinality
+	}
+	if num < 0 || num >= actions {
+		v.report(name, field+"Num", "%d out of range for tile %q, want 0 to %d", num, name, actions-1)
+		return false
+	}
+	return true
+}
+
+// Checks left/right and top/bottom rules, returning the valid ones, see
+// side for actions
+func (v *validator) neighbours(neighbours []Neighbour, actions int) []Neighbour {
+	valid := make([]Neighbour, 0, len(neighbours))
+	for i, n := range neighbours {
+		field := fmt.Sprintf("neighbors[%d]", i)
+		if n.Vertical() {
+			if n.Left != "" || n.Right != "" {
+				v.report("", field, "has both left/right and top/bottom")
+				continue
+			}
+			top := v.side(field+".top", n.Top, n.TopNum, actions)
+			bottom := v.side(field+".bottom", n.Bottom, n.BottomNum, actions)
+			if top && bottom {
+				valid = append(valid, n)
+			}
+			continue
+		}
+		left := v.side(field+".left", n.Left, n.LeftNum, actions)
+		right := v.side(field+".right", n.Right, n.RightNum, actions)
+		if left && right {
+			valid = append(valid, n)
+		}
+	}
+	return valid
+}
+
+// Reports every variant without a legal neighbour in some direction,
+// where[d] says where that neighbour sits and found(d, t) whether
+// variant t has one
+func (v *validator) stranded(names []string, first map[string]int, where []string, found func(d, t int) bool) {
+	for i, name := range names {
+		for variant := 0; variant < v.cardinalities[name]; variant++ {
+			t := first[name] + variant
+			for d := range where {
+				if !found(d, t) {
+					v.report(name, fmt.Sprintf("tiles[%d]", i), "variant %d has no legal neighbour %s", variant, where[d])
+				}
+			}
+		}
+	}
+}
+
+// The problems found as a *ValidationError, nil if there are none
+func (v *validator) err() error {
+	if len(v.issues) > 0 {
+		return &ValidationError{Issues: v.issues}
+	}
+	return nil
+}
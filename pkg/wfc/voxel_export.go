@@ -0,0 +1,121 @@
+package wfc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"io"
+)
+
+// A decided voxel in the json export
+type VoxelCell struct {
+	Tile    string `json:"tile"`    // Name of the data tile
+	Variant int    `json:"variant"` // Variant of the tile, quarter turns for rotating tiles
+}
+
+type voxelExport struct {
+	Width  int              `json:"width"`
+	Depth  int              `json:"depth"`
+	Height int              `json:"height"`
+	Voxels [][][]*VoxelCell `json:"voxels"` // [x][y][z], null if undecided
+}
+
+// Writes the voxels as json, see VoxelCell
+func (m *TiledModel3D) WriteJSON(w io.Writer) error {
+	voxels := m.Voxels()
+	out := voxelExport{
+		Width:  m.Width,
+		Depth:  m.Depth,
+		Height: m.Height,
+		Voxels: make([][][]*VoxelCell, m.Width),
+	}
+
+	for x := range voxels {
+		out.Voxels[x] = make([][]*VoxelCell, m.Depth)
+		for y := range voxels[x] {
+			out.Voxels[x][y] = make([]*VoxelCell, m.Height)
+			for z, t := range voxels[x][y] {
+				if t != -1 {
+					out.Voxels[x][y][z] = &VoxelCell{Tile: m.TileNames[m.TileOf[t]], Variant: m.Variant[t]}
+				}
+			}
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	return enc.Encode(out)
+}
+
+// Writes the voxels as a MagicaVoxel .vox file, one voxel per decided
+// cell coloured by its tile, empty tiles are left out
+func (m *TiledModel3D) WriteVox(w io.Writer) error {
+	if m.Width > 256 || m.Depth > 256 || m.Height > 256 {
+		return fmt.Errorf("wfc: .vox models are at most 256 voxels a side, got %dx%dx%d", m.Width, m.Depth, m.Height)
+	}
+
+	palette := make([]color.Color, 0)
+	index := make(map[color.RGBA]int)
+	xyzi := make([]byte, 0)
+	count := 0
+
+	voxels := m.Voxels()
+	for x := range voxels {
+		for y := range voxels[x] {
+			for z, t := range voxels[x][y] {
+				if t == -1 || m.Colors[t] == nil {
+					continue
+				}
+
+				c := color.RGBAModel.Convert(m.Colors[t]).(color.RGBA)
+				i, ok := index[c]
+				if !ok {
+					if len(palette) == 255 {
+						return fmt.Errorf("wfc: .vox models have at most 255 colours")
+					}
+					palette = append(palette, c)
+					i = len(palette)
+					index[c] = i
+				}
+
+				// MagicaVoxel y grows towards the back
+				xyzi = append(xyzi, byte(x), byte(m.Depth-1-y), byte(z), byte(i))
+				count++
+			}
+		}
+	}
+
+	var size, voxelChunk, rgba bytes.Buffer
+	binary.Write(&size, binary.LittleEndian, [3]int32{int32(m.Width), int32(m.Depth), int32(m.Height)})
+	binary.Write(&voxelChunk, binary.LittleEndian, int32(count))
+	voxelChunk.Write(xyzi)
+	for i := 0; i < 256; i++ {
+		if i < len(palette) {
+			r, g, b, a := palette[i].RGBA()
+			rgba.Write([]byte{byte(r >> 8), byte(g >> 8), byte(b >> 8), byte(a >> 8)})
+		} else {
+			rgba.Write([]byte{0, 0, 0, 0})
+		}
+	}
+
+	var children bytes.Buffer
+	writeVoxChunk(&children, "SIZE", size.Bytes(), nil)
+	writeVoxChunk(&children, "XYZI", voxelChunk.Bytes(), nil)
+	writeVoxChunk(&children, "RGBA", rgba.Bytes(), nil)
+
+	var file bytes.Buffer
+	file.WriteString("VOX ")
+	binary.Write(&file, binary.LittleEndian, int32(150))
+	writeVoxChunk(&file, "MAIN", nil, children.Bytes())
+
+	_, err := w.Write(file.Bytes())
+	return err
+}
+
+func writeVoxChunk(buf *bytes.Buffer, id string, content, children []byte) {
+	buf.WriteString(id)
+	binary.Write(buf, binary.LittleEndian, [2]int32{int32(len(content)), int32(len(children))})
+	buf.Write(content)
+	buf.Write(children)
+}
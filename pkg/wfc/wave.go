@@ -0,0 +1,124 @@
+package wfc
+
+import "math/bits"
+
+// A set of patterns, one bit per pattern
+type Bitset []uint64
+
+func NewBitset(n int) Bitset {
+	return make(Bitset, (n+63)/64)
+}
+
+func (s Bitset) Has(t int) bool {
+	return s[t>>6]&(1<<(t&63)) != 0
+}
+
+func (s Bitset) Add(t int) {
+	s[t>>6] |= 1 << (t & 63)
+}
+
+func (s Bitset) Remove(t int) {
+	s[t>>6] &^= 1 << (t & 63)
+}
+
+// Returns the number of patterns in the set
+func (s Bitset) Count() int {
+	n := 0
+	for _, w := range s {
+		n += bits.OnesCount64(w)
+	}
+	return n
+}
+
+// Returns the lowest pattern in the set, -1 if empty
+func (s Bitset) First() int {
+	for i, w := range s {
+		if w != 0 {
+			return i<<6 + bits.TrailingZeros64(w)
+		}
+	}
+	return -1
+}
+
+// Returns whether the sets share any pattern
+func (s Bitset) Intersects(o Bitset) bool {
+	for i := range s {
+		if s[i]&o[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Removes every pattern not in o
+func (s Bitset) And(o Bitset) {
+	for i := range s {
+		s[i] &= o[i]
+	}
+}
+
+// The possible patterns (t) of every cell (i), stored as one bitset per
+// cell in a single allocation
+type Wave struct {
+	T      int      // Count of patterns
+	stride int      // Words per cell
+	words  []uint64 // Bits of every cell
+}
+
+func NewWave(cells, T int) Wave {
+	stride := (T + 63) / 64
+	return Wave{
+		T:      T,
+		stride: stride,
+		words:  make([]uint64, cells*stride),
+	}
+}
+
+// Returns the number of cells
+func (w Wave) Cells() int {
+	if w.stride == 0 {
+		return 0
+	}
+	return len(w.words) / w.stride
+}
+
+// Returns the patterns of cell i, sharing storage with the wave
+func (w Wave) Cell(i int) Bitset {
+	return w.words[i*w.stride : (i+1)*w.stride]
+}
+
+func (w Wave) Get(i, t int) bool {
+	return w.words[i*w.stride+t>>6]&(1<<(t&63)) != 0
+}
+
+func (w Wave) Set(i, t int) {
+	w.words[i*w.stride+t>>6] |= 1 << (t & 63)
+}
+
+func (w Wave) Unset(i, t int) {
+	w.words[i*w.stride+t>>6] &^= 1 << (t & 63)
+}
+
+// Returns the number of patterns cell i allows
+func (w Wave) Count(i int) int {
+	return w.Cell(i).Count()
+}
+
+// Allows every pattern in every cell
+func (w Wave) Fill() {
+	if w.stride == 0 {
+		return
+	}
+
+	full := w.words[:w.stride]
+	for i := range full {
+		full[i] = ^uint64(0)
+	}
+	if r := w.T & 63; r != 0 {
+		full[w.stride-1] = 1<<r - 1
+	}
+
+	for i := w.stride; i < len(w.words); i += w.stride {
+		copy(w.words[i:i+w.stride], full)
+	}
+}
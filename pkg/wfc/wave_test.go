@@ -0,0 +1,46 @@
+package wfc
+
+import "testing"
+
+func TestWaveFillAndCount(t *testing.T) {
+	wave := NewWave(3, 70)
+	wave.Fill()
+
+	for i := 0; i < wave.Cells(); i++ {
+		if n := wave.Count(i); n != 70 {
+			t.Fatalf("Expected 70 patterns in cell %d, got %d.", i, n)
+		}
+	}
+
+	wave.Unset(1, 0)
+	wave.Unset(1, 69)
+	if wave.Get(1, 69) || !wave.Get(0, 69) || !wave.Get(2, 0) {
+		t.Fatal("Unset leaked into a neighbouring cell.")
+	}
+	if n := wave.Count(1); n != 68 {
+		t.Fatalf("Expected 68 patterns, got %d.", n)
+	}
+	if first := wave.Cell(1).First(); first != 1 {
+		t.Fatalf("Expected first pattern 1, got %d.", first)
+	}
+}
+
+func TestBitsetIntersects(t *testing.T) {
+	a := NewBitset(100)
+	b := NewBitset(100)
+	a.Add(3)
+	b.Add(99)
+	if a.Intersects(b) {
+		t.Fatal("Disjoint sets intersect.")
+	}
+
+	b.Add(3)
+	if !a.Intersects(b) {
+		t.Fatal("Sets sharing pattern 3 do not intersect.")
+	}
+
+	b.And(a)
+	if b.Count() != 1 || !b.Has(3) {
+		t.Fatal("Expected And to keep only pattern 3.")
+	}
+}
@@ -0,0 +1,333 @@
+package wfc
+
+import (
+	"encoding/xml"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"wfc/pkg/utils"
+)
+
+// Tileset of the original WaveFunctionCollapse data.xml format
+type xmlSet struct {
+	Size      int           `xml:"size,attr"`          // Width and height of square tiles, defaults to the first image
+	Unique    bool          `xml:"unique,attr"`        //
+	Tiles     []xmlTile     `xml:"tiles>tile"`         //
+	Neighbors []xmlNeighbor `xml:"neighbors>neighbor"` //
+	Subsets   []xmlSubset   `xml:"subsets>subset"`     //
+}
+
+type xmlTile struct {
+	Name     string  `xml:"name,attr"`
+	Symmetry string  `xml:"symmetry,attr"` // Default to X
+	Weight   float64 `xml:"weight,attr"`   // Default to 1
+}
+
+// Sides are "name" or "name num"
+type xmlNeighbor struct {
+	Left   string `xml:"left,attr"`
+	Right  string `xml:"right,attr"`
+	Top    string `xml:"top,attr"`    // Only needed for non square tiles
+	Bottom string `xml:"bottom,attr"` // Only needed for non square tiles
+}
+
+type xmlSubset struct {
+	Name  string    `xml:"name,attr"`
+	Tiles []xmlTile `xml:"tile"`
+}
+
+// Same as LoadXMLData but panics on error
+func MakeXMLData(file, subset string) TiledData {
+	data, err := LoadXMLData(file, subset)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// Reads a tileset in the format of the original WaveFunctionCollapse
+// samples and its images. A file named data.xml has its images next to
+// it, any other file, like tilesets/Summer.xml, in the directory of the
+// same name. Images are "name.png", or "name 0.png" onwards for every
+// variant of a unique tileset. A non empty subset keeps only the tiles
+// of that subset and the rules between them. Errors reading files are a
+// *FileError or *TileError
+func LoadXMLData(file, subset string) (TiledData, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return TiledData{}, &FileError{Path: file, Err: err}
+	}
+
+	var set xmlSet
+	if err := xml.Unmarshal(content, &set); err != nil {
+		return TiledData{}, fmt.Errorf("wfc: parsing %s: %w", file, err)
+	}
+
+	dir := filepath.Dir(file)
+	if base := filepath.Base(file); base != "data.xml" {
+		dir = filepath.Join(dir, strings.TrimSuffix(base, filepath.Ext(base)))
+	}
+
+	var keep map[string]bool
+	if subset != "" {
+		for _, s := range set.Subsets {
+			if s.Name == subset {
+				keep = make(map[string]bool)
+				for _, t := range s.Tiles {
+					keep[t.Name] = true
+				}
+			}
+		}
+		if keep == nil {
+			return TiledData{}, fmt.Errorf("wfc: parsing %s: unknown subset %q", file, subset)
+		}
+	}
+
+	data := TiledData{Unique: set.Unique}
+	for _, xt := range set.Tiles {
+		if keep != nil && !keep[xt.Name] {
+			continue
+		}
+
+		tile := Tile{Name: xt.Name, Sym: xt.Symmetry, Weight: rawWeight(xt.Weight)}
+		if tile.Sym == "" {
+			tile.Sym = "X"
+		}
+
+		paths := []string{filepath.Join(dir, xt.Name+".png")}
+		if set.Unique {
+			cardinality, _, _, _ := tileSymmetry(tile.Sym)
+			paths = paths[:0]
+			for i := 0; i < cardinality; i++ {
+				paths = append(paths, filepath.Join(dir, xt.Name+" "+strconv.Itoa(i)+".png"))
+			}
+		}
+		for _, path := range paths {
+			img, err := utils.LoadImage(path)
+			if err != nil {
+				return TiledData{}, &TileError{Tile: xt.Name, Path: path, Err: err}
+			}
+			tile.Variants = append(tile.Variants, img)
+		}
+		data.Tiles = append(data.Tiles, tile)
+	}
+
+	data.TileWidth, data.TileHeight = set.Size, set.Size
+	if set.Size == 0 && len(data.Tiles) > 0 {
+		size := data.Tiles[0].Variants[0].Bounds().Size()
+		data.TileWidth, data.TileHeight = size.X, size.Y
+	}
+
+	side := func(field, value string) (string, int, bool, error) {
+		fields := strings.Fields(value)
+		if len(fields) == 0 || len(fields) > 2 {
+			return "", 0, false, fmt.Errorf("wfc: parsing %s: neighbor %s: want name or name num, got %q", file, field, value)
+		}
+		num := 0
+		if len(fields) == 2 {
+			n, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return "", 0, false, fmt.Errorf("wfc: parsing %s: neighbor %s: %w", file, field, err)
+			}
+			num = n
+		}
+		return fields[0], num, keep == nil || keep[fields[0]], nil
+	}
+
+	for _, xn := range set.Neighbors {
+		var n Neighbour
+		var ok1, ok2 bool
+		if xn.Top != "" || xn.Bottom != "" {
+			if n.Top, n.TopNum, ok1, err = side("top", xn.Top); err != nil {
+				return TiledData{}, err
+			}
+			if n.Bottom, n.BottomNum, ok2, err = side("bottom", xn.Bottom); err != nil {
+				return TiledData{}, err
+			}
+		} else {
+			if n.Left, n.LeftNum, ok1, err = side("left", xn.Left); err != nil {
+				return TiledData{}, err
+			}
+			if n.Right, n.RightNum, ok2, err = side("right", xn.Right); err != nil {
+				return TiledData{}, err
+			}
+		}
+		if ok1 && ok2 {
+			data.Neighbors = append(data.Neighbors, n)
+		}
+	}
+
+	return data.sized(), nil
+}
+
+// Sample kinds of a samples.xml
+const (
+	SampleOverlapping = "overlapping"
+	SampleTiled       = "simpletiled"
+)
+
+// An entry of the original WaveFunctionCollapse samples.xml, see
+// LoadSamples
+type Sample struct {
+	Kind          string // SampleOverlapping or SampleTiled
+	Name          string // Sample image or tileset, without extension
+	N             int    // Pattern size of overlapping samples, default to 3
+	Width         int    // Default to 48 for overlapping samples and 24 for tiled ones
+	Height        int    // Default to Width
+	PeriodicInput bool   // Default to true
+	Periodic      bool   // Default to false
+	Symmetry      int    // Default to 8
+	Ground        bool   // Default to false
+	Subset        string // Tileset subset, empty for every tile
+	Limit         int    // Maximum number of observations, 0 for no limit
+	Screenshots   int    // Number of outputs to generate, default to 2
+}
+
+type xmlSamples struct {
+	Samples []xmlSample `xml:",any"`
+}
+
+type xmlSample struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+}
+
+// Reads the overlapping and simpletiled entries of a samples.xml, other
+// entries and attributes are skipped
+func LoadSamples(file string) ([]Sample, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, &FileError{Path: file, Err: err}
+	}
+
+	var xs xmlSamples
+	if err := xml.Unmarshal(content, &xs); err != nil {
+		return nil, fmt.Errorf("wfc: parsing %s: %w", file, err)
+	}
+
+	samples := make([]Sample, 0, len(xs.Samples))
+	for i, x := range xs.Samples {
+		s := Sample{
+			Kind:          x.XMLName.Local,
+			N:             3,
+			PeriodicInput: true,
+			Symmetry:      8,
+			Screenshots:   2,
+		}
+		switch s.Kind {
+		case SampleOverlapping:
+			s.Width = 48
+		case SampleTiled:
+			s.Width = 24
+		default:
+			continue
+		}
+		s.Height = -1
+
+		for _, a := range x.Attrs {
+			if err := s.set(a.Name.Local, a.Value); err != nil {
+				return nil, fmt.Errorf("wfc: parsing %s: sample %d: %s: %w", file, i, a.Name.Local, err)
+			}
+		}
+		if s.Name == "" {
+			return nil, fmt.Errorf("wfc: parsing %s: sample %d: missing name", file, i)
+		}
+		if s.Height == -1 {
+			s.Height = s.Width
+		}
+		samples = append(samples, s)
+	}
+	return samples, nil
+}
+
+// Sets a field of the sample from an attribute
+func (s *Sample) set(name, value string) error {
+	var err error
+	switch name {
+	case "name":
+		s.Name = value
+	case "N":
+		s.N, err = strconv.Atoi(value)
+	case "size":
+		s.Width, err = strconv.Atoi(value)
+		s.Height = s.Width
+	case "width":
+		s.Width, err = strconv.Atoi(value)
+	case "height":
+		s.Height, err = strconv.Atoi(value)
+	case "periodicInput":
+		s.PeriodicInput, err = strconv.ParseBool(value)
+	case "periodic":
+		s.Periodic, err = strconv.ParseBool(value)
+	case "symmetry":
+		s.Symmetry, err = strconv.Atoi(value)
+	case "ground":
+		// Older samples give the ground pattern as a number, 0 for none
+		if n, nerr := strconv.Atoi(value); nerr == nil {
+			s.Ground = n != 0
+		} else {
+			s.Ground, err = strconv.ParseBool(value)
+		}
+	case "subset":
+		s.Subset = value
+	case "limit":
+		s.Limit, err = strconv.Atoi(value)
+		if s.Limit < 0 {
+			s.Limit = 0
+		}
+	case "screenshots":
+		s.Screenshots, err = strconv.Atoi(value)
+	}
+	return err
+}
+
+// Finds the sample image, or tileset data file, in dir or the samples
+// and tilesets directories of the original repository layout
+func (s Sample) Path(dir string) (string, error) {
+	var candidates []string
+	if s.Kind == SampleOverlapping {
+		candidates = []string{
+			filepath.Join(dir, "samples", s.Name+".png"),
+			filepath.Join(dir, s.Name+".png"),
+		}
+	} else {
+		candidates = []string{
+			filepath.Join(dir, "tilesets", s.Name+".xml"),
+			filepath.Join(dir, s.Name+".xml"),
+			filepath.Join(dir, "samples", s.Name, "data.xml"),
+			filepath.Join(dir, s.Name, "data.xml"),
+		}
+	}
+
+	for _, path := range candidates {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", &FileError{Path: candidates[0], Err: os.ErrNotExist}
+}
+
+// Loads the sample image of an overlapping sample
+func (s Sample) Image(dir string) (image.Image, error) {
+	path, err := s.Path(dir)
+	if err != nil {
+		return nil, err
+	}
+	img, err := utils.LoadImage(path)
+	if err != nil {
+		return nil, &FileError{Path: path, Err: err}
+	}
+	return img, nil
+}
+
+// Loads the tileset of a tiled sample, restricted to its subset
+func (s Sample) Data(dir string) (TiledData, error) {
+	path, err := s.Path(dir)
+	if err != nil {
+		return TiledData{}, err
+	}
+	return LoadXMLData(path, s.Subset)
+}
@@ -0,0 +1,86 @@
+package wfc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestXMLMatchesJSONData(t *testing.T) {
+	xml := MakeXMLData("../../internal/input/castle.xml", "")
+	if err := xml.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	data := MakeTiledData("../../internal/input/", "castle_data.json")
+
+	fromXML := NewTiledModel(xml, 5, 5, false)
+	fromJSON := NewTiledModel(data, 5, 5, false)
+	if !reflect.DeepEqual(fromXML.TileNames, fromJSON.TileNames) {
+		t.Fatalf("Tile names %v, want %v.", fromXML.TileNames, fromJSON.TileNames)
+	}
+	if !reflect.DeepEqual(fromXML.Propagator, fromJSON.Propagator) {
+		t.Fatal("Propagator differs from the json data.")
+	}
+}
+
+func TestXMLSubset(t *testing.T) {
+	data, err := LoadXMLData("../../internal/input/castle.xml", "Roads")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := data.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	keep := map[string]bool{"ground": true, "road": true, "roadturn": true, "t": true}
+	if len(data.Tiles) != len(keep) {
+		t.Fatalf("Subset has %d tiles, want %d.", len(data.Tiles), len(keep))
+	}
+	for _, tile := range data.Tiles {
+		if !keep[tile.Name] {
+			t.Fatalf("Tile %q is not in the subset.", tile.Name)
+		}
+	}
+	for _, n := range data.Neighbors {
+		if !keep[n.Left] || !keep[n.Right] {
+			t.Fatalf("Rule %+v uses a tile outside the subset.", n)
+		}
+	}
+
+	if _, err := LoadXMLData("../../internal/input/castle.xml", "Missing"); err == nil {
+		t.Fatal("Expected an error for an unknown subset.")
+	}
+}
+
+func TestLoadSamples(t *testing.T) {
+	samples, err := LoadSamples("../../internal/input/samples.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Sample{
+		{Kind: SampleOverlapping, Name: "flowers", N: 3, Width: 32, Height: 24, PeriodicInput: true, Periodic: true, Symmetry: 2, Ground: true, Screenshots: 1},
+		{Kind: SampleTiled, Name: "castle", N: 3, Width: 12, Height: 12, PeriodicInput: true, Symmetry: 8, Screenshots: 2},
+		{Kind: SampleTiled, Name: "castle", N: 3, Width: 10, Height: 8, PeriodicInput: true, Symmetry: 8, Subset: "Roads", Screenshots: 2},
+	}
+	if !reflect.DeepEqual(samples, want) {
+		t.Fatalf("Samples %+v, want %+v.", samples, want)
+	}
+
+	for _, s := range samples {
+		if _, err := s.Path("../../internal/input"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tiled := samples[2]
+	data, err := tiled.Data("../../internal/input")
+	if err != nil {
+		t.Fatal(err)
+	}
+	model := NewTiledModel(data, tiled.Width, tiled.Height, tiled.Periodic)
+	model.SetSeed(1)
+	model.SetBacktracking(0)
+	if _, success := model.Generate(); !success {
+		t.Fatal("Failed to generate the subset sample with backtracking.")
+	}
+}
@@ -1,2 +1,3 @@
 go mod tidy
-go run cmd/cli/main.go
\ No newline at end of file
+go run ./cmd/cli tiled -data internal/input/pipe_data.json -width 20 -height 20 -out internal/output/img.jpg
+go run ./cmd/cli overlap -input internal/input/maze.png -n 3 -width 16 -height 16 -periodic -symmetry 2 -out internal/output/img2.jpg